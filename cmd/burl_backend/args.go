@@ -19,10 +19,13 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/maxnikulin/burl/pkg/burl_emacs"
 	"github.com/maxnikulin/burl/pkg/burl_fileutil"
 	"github.com/maxnikulin/burl/pkg/burl_links"
+	"github.com/maxnikulin/burl/pkg/burl_url"
 	"github.com/maxnikulin/burl/pkg/burl_util"
 )
 
@@ -30,12 +33,19 @@ type BurlBackendArgs struct {
 	DisableLinkSet bool
 	Exe            string
 	LogFile        string
+	CacheFile      string
+	ReloadInterval time.Duration
 	LinkSources    burl_links.MixedSrcTypeSlice
 	Scheme         burl_util.MultiStringFlag
+	StripParam     burl_util.MultiStringFlag
+	UrlRules       burl_util.MultiStringFlag
+	MessageArchive burl_util.MultiStringFlag
+	urlRulesFiles  []string
 	EmacsArgs      burl_util.MultiStringFlag
 }
 
 var DefaultLogDestination string = "-"
+var DefaultReloadInterval time.Duration = 2 * time.Second
 
 func AddBackendFlags(flagset *flag.FlagSet) *BurlBackendArgs {
 	if flagset == nil {
@@ -44,14 +54,36 @@ func AddBackendFlags(flagset *flag.FlagSet) *BurlBackendArgs {
 	v := BurlBackendArgs{
 		DisableLinkSet: false,
 		LogFile:        DefaultLogDestination,
+		ReloadInterval: DefaultReloadInterval,
 		LinkSources:    make(burl_links.MixedSrcTypeSlice, 0, 4),
 		Scheme:         *burl_util.NewMultiStringFlag(&burl_links.SchemeVariants),
+		StripParam:     *burl_util.NewMultiStringFlag(&burl_url.TrackingParams),
+		MessageArchive: *burl_util.NewMultiStringFlag(&burl_url.MessageArchiveTemplates),
 		EmacsArgs:      *burl_util.NewMultiStringFlag(&burl_emacs.UserArgs),
 	}
+	v.UrlRules = *burl_util.NewMultiStringFlag(&v.urlRulesFiles)
 	flagset.StringVar(&v.LogFile, "log", DefaultLogDestination,
 		"`FILE` name for logging, \"\" to disable looging, \"-\" for stderr")
+	flagset.DurationVar(&v.ReloadInterval, "reload-interval", DefaultReloadInterval,
+		"Minimal `INTERVAL` between checks whether files are modified, negative to read files once")
+	cacheHint := "e.g. $XDG_CACHE_HOME/burl/links.cache"
+	if path, err := burl_links.DefaultCachePath(); err == nil {
+		cacheHint = "e.g. " + path
+	}
+	flagset.StringVar(&v.CacheFile, "cache", "",
+		"Store links extracted from files in `FILE` ("+cacheHint+
+			") to avoid parsing of unchanged files at next launch")
 	flagset.Var(&v.Scheme, "scheme",
 		"Add `SCHEME` to pattern for link extraction")
+	flagset.Var(&v.StripParam, "strip-param",
+		"Add `PATTERN` of query parameters ignored when URLs are compared"+
+			" (default utm_*, fbclid, gclid, etc.), \"\" to reset")
+	flagset.Var(&v.MessageArchive, "message-archive",
+		"Add `TEMPLATE` of mail archive URLs equivalent to mid: links,"+
+			" e.g. https://list.orgmode.org/%s/, \"\" to reset")
+	flagset.Var(&v.UrlRules, "url-rules",
+		"Read `FILE` with \"REGEXP REPLACEMENT\" lines mapping alternative forms of URLs"+
+			" to preferred ones, they are applied before built-in rules")
 	flagset.BoolVar(&v.DisableLinkSet, "disable-link-set", false,
 		"Do not allow linkSet method for extracting of all links by e.g. https: prefix")
 	flagset.StringVar(&burl_emacs.Command, "emacsclient", burl_emacs.Command,
@@ -65,7 +97,12 @@ func (a *BurlBackendArgs) Customized() bool {
 	return (a.LogFile != DefaultLogDestination ||
 		len(a.LinkSources) != 0 ||
 		a.DisableLinkSet ||
+		a.ReloadInterval != DefaultReloadInterval ||
+		a.CacheFile != "" ||
 		a.Scheme.IsModified() ||
+		a.StripParam.IsModified() ||
+		a.UrlRules.IsModified() ||
+		a.MessageArchive.IsModified() ||
 		a.EmacsArgs.IsModified() ||
 		burl_emacs.Command != "emacsclient")
 }
@@ -79,6 +116,11 @@ func (a *BurlBackendArgs) FixPaths() error {
 	if a.Exe, err = burl_fileutil.RealPath(a.Exe); err != nil {
 		return fmt.Errorf("current executable: %w", err)
 	}
+	if a.CacheFile != "" {
+		if a.CacheFile, err = filepath.Abs(a.CacheFile); err != nil {
+			return fmt.Errorf("cache file: %w", err)
+		}
+	}
 	if a.LogFile != "" && a.LogFile != "-" {
 		a.LogFile, _, err =
 			burl_fileutil.AsAbsFileName(a.LogFile, burl_fileutil.AbsFileNameOptions{})
@@ -86,7 +128,15 @@ func (a *BurlBackendArgs) FixPaths() error {
 			return err
 		}
 	}
+	for i, path := range a.urlRulesFiles {
+		if a.urlRulesFiles[i], err = filepath.Abs(path); err != nil {
+			return fmt.Errorf("url rules: %w", err)
+		}
+	}
 	for i, s := range a.LinkSources {
+		if !burl_links.IsFileSource(s) {
+			continue
+		}
 		if path, err := burl_fileutil.RealPath(s.Name()); err == nil {
 			a.LinkSources[i] = s.Clone(path)
 		} else {
@@ -107,6 +157,16 @@ func (a *BurlBackendArgs) AsShellCommand() ([]string, error) {
 	if a.DisableLinkSet {
 		retval = append(retval, "--disable-link-set=true")
 	}
+	if a.ReloadInterval != DefaultReloadInterval {
+		retval = append(retval, "--reload-interval="+a.ReloadInterval.String())
+	}
+	if a.CacheFile != "" {
+		escaped, err := burl_fileutil.EscapeShellArg(a.CacheFile)
+		if err != nil {
+			return retval, err
+		}
+		retval = append(retval, "--cache="+escaped)
+	}
 	if burl_emacs.Command != "emacsclient" {
 		escaped, err := burl_fileutil.EscapeShellArg(burl_emacs.Command)
 		if err != nil {
@@ -132,7 +192,44 @@ func (a *BurlBackendArgs) AsShellCommand() ([]string, error) {
 			retval = append(retval, "--scheme="+escaped)
 		}
 	}
+	if a.StripParam.IsModified() {
+		for _, arg := range a.StripParam.ModifiedValues() {
+			escaped, err := burl_fileutil.EscapeShellArg(arg)
+			if err != nil {
+				return retval, err
+			}
+			retval = append(retval, "--strip-param="+escaped)
+		}
+	}
+	if a.MessageArchive.IsModified() {
+		for _, arg := range a.MessageArchive.ModifiedValues() {
+			escaped, err := burl_fileutil.EscapeShellArg(arg)
+			if err != nil {
+				return retval, err
+			}
+			retval = append(retval, "--message-archive="+escaped)
+		}
+	}
+	for _, path := range a.urlRulesFiles {
+		escaped, err := burl_fileutil.EscapeShellArg(path)
+		if err != nil {
+			return retval, err
+		}
+		retval = append(retval, "--url-rules="+escaped)
+	}
+	// Source options are sticky, so they are passed only when changed.
+	options := &burl_links.DefaultSourceOptions
 	for _, s := range a.LinkSources {
+		if _, sourceOptions := burl_links.UnwrapSource(s); !sourceOptions.Equal(options) {
+			for _, arg := range sourceOptions.Args() {
+				escaped, err := burl_fileutil.EscapeShellArg(arg)
+				if err != nil {
+					return retval, err
+				}
+				retval = append(retval, escaped)
+			}
+			options = sourceOptions
+		}
 		value, err := burl_fileutil.EscapeShellArg(s.Name())
 		if err != nil {
 			return retval, err
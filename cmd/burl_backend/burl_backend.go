@@ -26,6 +26,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/maxnikulin/burl/pkg/burl_emacs"
 	"github.com/maxnikulin/burl/pkg/burl_fuzzy"
@@ -38,7 +39,7 @@ import (
 
 func Usage() {
 	out := flag.CommandLine.Output()
-	fmt.Fprintf(out, "Usage: %s [-log LOG_FILE] [{-txt TEXT_FILE|-org ORG_FILE}...]\n", os.Args[0])
+	fmt.Fprintf(out, "Usage: %s [-log LOG_FILE] [{-txt TEXT_FILE|-org ORG_FILE|-md MARKDOWN_FILE|-bookmarks-html FILE|-bookmarks-json FILE|-mbox FILE|-maildir DIR|-bib FILE|-csl-json FILE|-dir DIR|-files-from LIST_FILE|-exec-source COMMAND}...]\n", os.Args[0])
 	fmt.Fprintf(out, "   or: %s [-force] -wrapper SCRIPT_FILE [BACKEND_OPTIONS...]\n", os.Args[0])
 	fmt.Fprintf(out, "   or: %s [-force] [-backend NAME] {-manifest-chrome|-manifest-firefox} DIR/[NAME] [WRAPPER_OPTIONS...]\n", os.Args[0])
 	fmt.Fprintf(out, "   or: %s {-h|--help|--version}\n", os.Args[0])
@@ -91,33 +92,93 @@ There is NO WARRANTY, to the extent permitted by law.
 
 // JSON-RPC endpoint
 type BurlBackend struct {
-	srcFiles    []burl_links.TextLinkSource
-	fileGroup   *burl_links.TreeChildrenNode
-	err         error
-	once        sync.Once
-	initializer func()
+	srcFiles       []burl_links.TextLinkSource
+	reloadInterval time.Duration
+	cache          *burl_links.LinkCache
+	// Serializes reading of files, snapshot may be used meanwhile.
+	updateMutex sync.Mutex
+	// Protects snapshot and checkTime.
+	mutex       sync.RWMutex
+	snapshot    *burl_links.FileGroupSnapshot
+	checkTime   time.Time
 	linkSetImpl func([]burl_links.TextLinkSource, []string, *burl_rpc.LinkSetResponse) error
 }
 
 func NewBurlBackendPtr(args *BurlBackendArgs) *BurlBackend {
-	backend := BurlBackend{args.LinkSources, nil, nil, sync.Once{}, nil, nil}
+	backend := BurlBackend{
+		srcFiles:       args.LinkSources,
+		reloadInterval: args.ReloadInterval,
+	}
 	if !args.DisableLinkSet {
 		backend.linkSetImpl = LinkSetReal
 	}
-	backend.initializer = func() {
-		if len(backend.srcFiles) == 0 {
-			backend.err = errors.New("No files specified for backend")
-			return
+	if args.CacheFile != "" {
+		backend.cache = burl_links.OpenLinkCache(args.CacheFile)
+	}
+	return &backend
+}
+
+func (b *BurlBackend) currentSnapshot() (*burl_links.FileGroupSnapshot, time.Time) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.snapshot, b.checkTime
+}
+
+func (b *BurlBackend) needsCheck(snapshot *burl_links.FileGroupSnapshot, checkTime time.Time) bool {
+	if snapshot == nil {
+		return true
+	}
+	interval := b.reloadInterval
+	if interval < 0 {
+		if snapshot.Err == nil {
+			return false
 		}
-		backend.fileGroup, backend.err =
-			burl_links.ExtractLinksFromFileGroup(backend.srcFiles, nil)
-		if backend.err != nil {
-			log.Println("Lazy read files:", backend.err)
-		} else if backend.fileGroup == nil {
-			backend.err = errors.New("No link in source files")
+		interval = DefaultReloadInterval
+	}
+	return time.Since(checkTime) >= interval
+}
+
+// Lazy read of files. Later calls check whether files are modified
+// at most once per reloadInterval and re-read only changed ones.
+// Failed attempts are repeated even if reloading is disabled.
+// Links from successfully read files are available despite failures.
+func (b *BurlBackend) fileGroup() (*burl_links.FileGroupSnapshot, error) {
+	if len(b.srcFiles) == 0 {
+		return nil, errors.New("No files specified for backend")
+	}
+	snapshot, checkTime := b.currentSnapshot()
+	if b.needsCheck(snapshot, checkTime) {
+		b.updateMutex.Lock()
+		defer b.updateMutex.Unlock()
+		// Another request may complete update while this one waited for the lock.
+		if snapshot, checkTime = b.currentSnapshot(); b.needsCheck(snapshot, checkTime) {
+			updated := burl_links.UpdateFileGroup(snapshot, b.srcFiles, nil, b.cache)
+			if updated != snapshot {
+				if updated.Err != nil {
+					log.Println("Lazy read files:", updated.Err)
+				} else {
+					log.Printf("Read files: %d sources", len(updated.Files))
+				}
+				if b.cache != nil {
+					if err := b.cache.Save(); err != nil {
+						log.Println("Save link cache:", err)
+					}
+				}
+			}
+			b.mutex.Lock()
+			b.snapshot = updated
+			b.checkTime = time.Now()
+			b.mutex.Unlock()
+			snapshot = updated
 		}
 	}
-	return &backend
+	if snapshot.Tree == nil {
+		if snapshot.Err != nil {
+			return nil, snapshot.Err
+		}
+		return nil, errors.New("No link in source files")
+	}
+	return snapshot, nil
 }
 
 func (b *BurlBackend) Hello(query *burl_rpc.HelloQuery, reply *burl_rpc.HelloResponse) error {
@@ -167,29 +228,29 @@ func (b *BurlBackend) UrlMentions(query *burl_rpc.UrlMentionsQuery, reply *burl_
 	for _, u := range query.Variants {
 		if u != "" {
 			hasUrl = true
-			variants = append(variants, burl_url.UrlVariants(u)...)
+			// Compared by burl_url.CanonicalKey in the index.
+			variants = append(variants, u)
 		}
 	}
 	if !hasUrl {
 		return errors.New("No URLs in the query")
 	}
-	b.once.Do(b.initializer)
-	if b.err != nil {
-		return b.err
-	}
-	filter := func(link *burl_links.Link) bool {
-		for _, l := range variants {
-			if l == link.URL {
-				return true
-			}
-		}
-		return false
+	snapshot, err := b.fileGroup()
+	if err != nil {
+		return err
 	}
 	countLimit := 8
 	if query.Options != nil {
 		countLimit = query.Options.CountLimit
 	}
-	attrTree := burl_links.CountDescendants(b.fileGroup, filter)
+	attrTree := snapshot.Index.CountMentions(variants)
+	if attrTree == nil {
+		// The same response as for a tree without matched links
+		attrTree = &burl_links.LimitCountNode{
+			Attrs: &burl_links.LimitCountAttrs{},
+			Node:  snapshot.Tree.Clone(nil),
+		}
+	}
 	attrTree = burl_links.FilterChildrenCount(attrTree, countLimit)
 	*reply = *attrTree
 	return nil
@@ -203,6 +264,12 @@ func (b *BurlBackend) Visit(query *burl_rpc.Location, result *bool) error {
 			break
 		}
 	}
+	if !fileAllowed {
+		// Files found in directories
+		if snapshot, err := b.fileGroup(); err == nil {
+			fileAllowed = snapshot.HasFile(query.FilePath)
+		}
+	}
 	if !fileAllowed {
 		return errors.New("Opening of arbitrary file is prohibited")
 	}
@@ -214,9 +281,9 @@ func (b *BurlBackend) Visit(query *burl_rpc.Location, result *bool) error {
 }
 
 func (b *BurlBackend) Search(query *burl_rpc.SearchQuery, reply *[]burl_rpc.ReplyRecord) error {
-	b.once.Do(b.initializer)
-	if b.err != nil {
-		return b.err
+	snapshot, err := b.fileGroup()
+	if err != nil {
+		return err
 	}
 
 	source := func(cb func(string) bool) {
@@ -224,7 +291,7 @@ func (b *BurlBackend) Search(query *burl_rpc.SearchQuery, reply *[]burl_rpc.Repl
 			cb(link.URL)
 			return true
 		}
-		burl_links.ForEachLink(b.fileGroup, filter)
+		burl_links.ForEachLink(snapshot.Tree, filter)
 	}
 
 	if query.Limit != nil {
@@ -249,17 +316,23 @@ func (b *BurlBackend) Search(query *burl_rpc.SearchQuery, reply *[]burl_rpc.Repl
 	return nil
 }
 
-func LinkSetReal(srcFiles []burl_links.TextLinkSource, prefixes []string, reply *burl_rpc.LinkSetResponse) error {
-	urls, err := burl_links.ExtractLinkSetFromFileGroup(srcFiles, prefixes)
-	if err != nil {
-		return err
-	}
+func linkSetResponse(urls map[string]bool, reply *burl_rpc.LinkSetResponse) {
 	reply.Urls = make([]string, len(urls))
 	i := 0
 	for key := range urls {
 		reply.Urls[i] = key
 		i++
 	}
+}
+
+func LinkSetReal(srcFiles []burl_links.TextLinkSource, prefixes []string, reply *burl_rpc.LinkSetResponse) error {
+	urls, err := burl_links.ExtractLinkSetFromFileGroup(srcFiles, prefixes)
+	if _, partial := err.(burl_links.SourceErrors); partial {
+		log.Println("linkSet:", err)
+	} else if err != nil {
+		return err
+	}
+	linkSetResponse(urls, reply)
 	return nil
 }
 
@@ -271,6 +344,20 @@ func (b *BurlBackend) LinkSet(query *burl_rpc.LinkSetQuery, reply *burl_rpc.Link
 		log.Printf("prefixes %v\n", query.Prefix)
 		return errors.New("Too many prefix variants")
 	}
+	// Index has only links with schemes from SchemeVariants,
+	// files are parsed again for other prefixes.
+	if snapshot, err := b.fileGroup(); err != nil {
+		log.Println("linkSet: index is not available:", err)
+	} else {
+		urls := map[string]bool{}
+		ok, err := snapshot.Index.LinkSet(query.Prefix, urls)
+		if err != nil {
+			return err
+		} else if ok {
+			linkSetResponse(urls, reply)
+			return nil
+		}
+	}
 	return b.linkSetImpl(b.srcFiles, query.Prefix, reply)
 }
 
@@ -313,6 +400,16 @@ func mainWithGracefulShutdown() error {
 		}
 	}
 
+	var urlRules []*burl_url.RewriteRule
+	for _, path := range backendFlags.UrlRules.Values() {
+		rules, err := burl_url.LoadRewriteRules(path)
+		if err != nil {
+			return fmt.Errorf("url rules: %w", err)
+		}
+		urlRules = append(urlRules, rules...)
+	}
+	burl_url.UserRewriteRules = urlRules
+
 	backend := NewBurlBackendPtr(backendFlags)
 	err := rpc.RegisterName("Burl", backend)
 	if err != nil {
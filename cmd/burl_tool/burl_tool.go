@@ -63,7 +63,7 @@ func mainWithGracefulShutdown() error {
 	nextArg := 0
 	variants := make([]string, 0, 8)
 	if len(set) == 0 {
-		burl_url.UrlVariants(flag.Arg(0))
+		variants = append(variants, burl_url.CanonicalKey(flag.Arg(0)))
 		nextArg = 1
 	}
 	queryIsEmpty := len(variants) == 0
@@ -75,21 +75,19 @@ func mainWithGracefulShutdown() error {
 
 	if len(set) > 0 {
 		result, err := burl_links.ExtractLinkSetFromFileGroup(linkSources, set)
-		if err != nil {
-			return err
-		}
 		for key, _ := range result {
 			fmt.Println(key)
 		}
-		return nil
+		return err
 	}
 
 	filterExact := func(link *burl_links.Link) bool {
 		if queryIsEmpty {
 			return true
 		}
+		key := burl_url.CanonicalKey(link.URL)
 		for _, l := range variants {
-			if l == link.URL {
+			if l == key {
 				return true
 			}
 		}
@@ -0,0 +1,152 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_fileutil
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Convert shell glob to regexp matching slash-separated relative paths.
+// "*" and "?" do not match "/", "**" matches any number of directories.
+func GlobToRe(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	return b.String()
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// Subset of .gitignore rules: "!" negation, trailing "/" for directories,
+// leading or middle "/" to anchor pattern to the directory of the ignore file,
+// "**" to match any number of directories. The last matched pattern wins.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+// Add pattern relative to base directory (slash-separated, "" for root).
+// Empty lines and comments are skipped.
+func (r *IgnoreRules) Add(pattern string, base string) error {
+	pattern = strings.TrimRight(pattern, " \t\r")
+	if pattern == "" || strings.HasPrefix(pattern, "#") {
+		return nil
+	}
+	var p ignorePattern
+	if strings.HasPrefix(pattern, "!") {
+		p.negate = true
+		pattern = pattern[1:]
+	} else if strings.HasPrefix(pattern, `\`) {
+		pattern = pattern[1:]
+	}
+	if strings.HasSuffix(pattern, "/") {
+		p.dirOnly = true
+		pattern = strings.TrimRight(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return nil
+	}
+	prefix := "^"
+	if base != "" && base != "." {
+		prefix += regexp.QuoteMeta(path.Clean(base)) + "/"
+	}
+	if !anchored {
+		prefix += "(?:.*/)?"
+	}
+	re, err := regexp.Compile(prefix + GlobToRe(pattern) + "$")
+	if err != nil {
+		return err
+	}
+	p.re = re
+	r.patterns = append(r.patterns, p)
+	return nil
+}
+
+// Read patterns from file in directory base. Missed file is not an error.
+func (r *IgnoreRules) AddFile(filePath string, base string) error {
+	file, err := os.Open(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := r.Add(scanner.Text(), base); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Check slash-separated path relative to the root directory.
+func (r *IgnoreRules) Ignored(relPath string, isDir bool) bool {
+	ignored := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func (r *IgnoreRules) Empty() bool {
+	return len(r.patterns) == 0
+}
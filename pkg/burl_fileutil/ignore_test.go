@@ -0,0 +1,58 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_fileutil
+
+import "testing"
+
+var ignoreCases = []struct {
+	patterns []string
+	base     string
+	path     string
+	isDir    bool
+	ignored  bool
+}{
+	{[]string{"*.org"}, "", "notes.org", false, true},
+	{[]string{"*.org"}, "", "sub/dir/notes.org", false, true},
+	{[]string{"*.org"}, "", "notes.org.txt", false, false},
+	{[]string{"/notes.org"}, "", "sub/notes.org", false, false},
+	{[]string{"sub/*.org"}, "", "sub/notes.org", false, true},
+	{[]string{"sub/*.org"}, "", "sub/dir/notes.org", false, false},
+	{[]string{"sub/**/*.org"}, "", "sub/dir/deep/notes.org", false, true},
+	{[]string{"build/"}, "", "build", false, false},
+	{[]string{"build/"}, "", "build", true, true},
+	{[]string{"*.org", "!keep.org"}, "", "keep.org", false, false},
+	{[]string{"!keep.org", "*.org"}, "", "keep.org", false, true},
+	{[]string{"/local.org"}, "sub", "sub/local.org", false, true},
+	{[]string{"/local.org"}, "sub", "local.org", false, false},
+	{[]string{"# comment", "", "f[ab]?.txt"}, "", "fb1.txt", false, true},
+	{[]string{"f[!ab].txt"}, "", "fa.txt", false, false},
+}
+
+func TestIgnoreRules(t *testing.T) {
+	for _, c := range ignoreCases {
+		t.Run(c.path, func(t *testing.T) {
+			var rules IgnoreRules
+			for _, p := range c.patterns {
+				if err := rules.Add(p, c.base); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if actual := rules.Ignored(c.path, c.isDir); actual != c.ignored {
+				t.Errorf("%q %v: expected %v for %v", c.path, c.isDir, c.ignored, c.patterns)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Bibliography entry, its URL, DOI, and e-print identifier
+// are reported as links describing the entry.
+type BibEntry struct {
+	Key    string `json:"key"`
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title,omitempty"`
+	LineNo int    `json:"lineNo,omitempty"`
+}
+
+var _ TreeNodeProps = (*BibEntry)(nil)
+
+func (_ *BibEntry) BurlType() string {
+	return "BibEntry"
+}
+
+// Strip resolver to get "doi:" link, it is considered the same
+// by the existing doi scheme.
+func bibDoiLink(doi string) string {
+	doi = strings.TrimSpace(doi)
+	lower := strings.ToLower(doi)
+	for _, prefix := range []string{"https://doi.org/", "http://doi.org/", "https://dx.doi.org/", "http://dx.doi.org/", "doi:"} {
+		if strings.HasPrefix(lower, prefix) {
+			doi = doi[len(prefix):]
+			break
+		}
+	}
+	if doi == "" {
+		return ""
+	}
+	return "doi:" + doi
+}
+
+// Links for bibliography fields, keys are in lower case.
+func bibEntryNode(props *BibEntry, fields map[string]string, lineNo int, filter Filter) *TreeChildrenNode {
+	node := NewTreeChildrenNode(props)
+	add := func(url string, property string) {
+		if url == "" || !reScheme.MatchString(url) {
+			return
+		}
+		link := &Link{URL: url, LineNo: lineNo, Property: property}
+		if filter == nil || filter(link) {
+			node.AddLink(link)
+		}
+	}
+	add(strings.TrimSpace(fields["url"]), "url")
+	add(bibDoiLink(fields["doi"]), "doi")
+	if eprint := strings.TrimSpace(fields["eprint"]); eprint != "" {
+		if reScheme.MatchString(eprint) {
+			add(eprint, "eprint")
+		} else if strings.EqualFold(strings.TrimSpace(fields["archiveprefix"]), "arxiv") ||
+			strings.EqualFold(strings.TrimSpace(fields["eprinttype"]), "arxiv") {
+			add("https://arxiv.org/abs/"+eprint, "eprint")
+		}
+	}
+	if node.Empty() {
+		return nil
+	}
+	return &node
+}
+
+// BibTeX or BibLaTeX file, e.g. exported by Zotero with Better BibTeX.
+type BibtexLinkSource string
+
+var _ TextLinkSource = (*BibtexLinkSource)(nil)
+
+func (s BibtexLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ BibtexLinkSource) Flag() string {
+	return "bib"
+}
+
+func (_ BibtexLinkSource) Clone(src string) TextLinkSource {
+	v := BibtexLinkSource(src)
+	return &v
+}
+
+func (s BibtexLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	tree := NewTreeChildrenNode(nil)
+	parser := bibtexParser{text: string(content), lineNo: 1, macros: map[string]string{}}
+	failures := parser.parse(func(entryType string, key string, fields map[string]string, lineNo int) {
+		props := &BibEntry{Key: key, Type: entryType, Title: bibtexText(fields["title"]), LineNo: lineNo}
+		for name, value := range fields {
+			if name != "title" {
+				fields[name] = bibtexUnescape(value)
+			}
+		}
+		if node := bibEntryNode(props, fields, lineNo, filter); node != nil {
+			tree.AddChild(node)
+		}
+	})
+	for _, err := range failures {
+		log.Printf("burl_links.BibtexLinkSource: %s: entry skipped: %v", s, err)
+	}
+	return &tree, nil
+}
+
+func (s BibtexLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
+
+// Minimal parser of BibTeX entries sufficient to get field values.
+type bibtexParser struct {
+	text   string
+	pos    int
+	lineNo int
+	// @string definitions
+	macros map[string]string
+}
+
+func (p *bibtexParser) advance(pos int) {
+	p.lineNo += strings.Count(p.text[p.pos:pos], "\n")
+	p.pos = pos
+}
+
+func (p *bibtexParser) skipSpace() {
+	pos := p.pos
+	for pos < len(p.text) && unicode.IsSpace(rune(p.text[pos])) {
+		pos++
+	}
+	p.advance(pos)
+}
+
+func (p *bibtexParser) identifier() string {
+	start := p.pos
+	pos := start
+	for pos < len(p.text) && !strings.ContainsRune(" \t\r\n{}(),=#\"", rune(p.text[pos])) {
+		pos++
+	}
+	p.advance(pos)
+	return p.text[start:pos]
+}
+
+// Content of balanced braces or quotes, p.pos is at the opening character.
+func (p *bibtexParser) delimited() (string, error) {
+	open := p.text[p.pos]
+	start := p.pos + 1
+	depth := 0
+	for pos := start; pos < len(p.text); pos++ {
+		switch c := p.text[pos]; {
+		case c == '{':
+			depth++
+		case c == '}' && depth > 0:
+			depth--
+		case depth == 0 && (open == '{' && c == '}' || open == '"' && c == '"'):
+			p.advance(pos + 1)
+			return p.text[start:pos], nil
+		}
+	}
+	return "", fmt.Errorf("line %d: unbalanced %c", p.lineNo, open)
+}
+
+// Concatenation of strings and macros with "#".
+func (p *bibtexParser) value() (string, error) {
+	var parts []string
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return "", fmt.Errorf("line %d: unexpected end of file", p.lineNo)
+		}
+		switch p.text[p.pos] {
+		case '{', '"':
+			part, err := p.delimited()
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, part)
+		default:
+			name := p.identifier()
+			if name == "" {
+				return "", fmt.Errorf("line %d: value expected", p.lineNo)
+			}
+			if macro, ok := p.macros[strings.ToLower(name)]; ok {
+				name = macro
+			}
+			parts = append(parts, name)
+		}
+		p.skipSpace()
+		if p.pos < len(p.text) && p.text[p.pos] == '#' {
+			p.advance(p.pos + 1)
+			continue
+		}
+		return strings.Join(parts, ""), nil
+	}
+}
+
+// "name = value, ..." till closing delimiter.
+func (p *bibtexParser) fields(close byte) (map[string]string, error) {
+	fields := map[string]string{}
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.text) {
+			return fields, fmt.Errorf("line %d: unexpected end of file", p.lineNo)
+		}
+		if p.text[p.pos] == close {
+			p.advance(p.pos + 1)
+			return fields, nil
+		}
+		if p.text[p.pos] == ',' {
+			p.advance(p.pos + 1)
+			continue
+		}
+		name := strings.ToLower(p.identifier())
+		p.skipSpace()
+		if name == "" || p.pos >= len(p.text) || p.text[p.pos] != '=' {
+			return fields, fmt.Errorf("line %d: field expected", p.lineNo)
+		}
+		p.advance(p.pos + 1)
+		value, err := p.value()
+		if err != nil {
+			return fields, err
+		}
+		fields[name] = value
+	}
+}
+
+// Start of the next entry after a malformed one.
+var reBibtexEntryStart = regexp.MustCompile(`(?m)^[ \t]*@`)
+
+// Call fn for every entry. Text outside of entries is ignored
+// as comments. Malformed entries are skipped till the next line
+// starting with "@", their errors are returned.
+func (p *bibtexParser) parse(fn func(entryType string, key string, fields map[string]string, lineNo int)) []error {
+	var failures []error
+	for {
+		at := strings.IndexByte(p.text[p.pos:], '@')
+		if at < 0 {
+			return failures
+		}
+		p.advance(p.pos + at + 1)
+		entryPos, lineNo := p.pos, p.lineNo
+		if err := p.entry(fn); err != nil {
+			failures = append(failures, err)
+			p.pos, p.lineNo = entryPos, lineNo
+			loc := reBibtexEntryStart.FindStringIndex(p.text[p.pos:])
+			if loc == nil {
+				return failures
+			}
+			p.advance(p.pos + loc[0])
+		}
+	}
+}
+
+// Entry after "@".
+func (p *bibtexParser) entry(fn func(entryType string, key string, fields map[string]string, lineNo int)) error {
+	lineNo := p.lineNo
+	entryType := strings.ToLower(p.identifier())
+	p.skipSpace()
+	if p.pos >= len(p.text) {
+		return nil
+	}
+	open := p.text[p.pos]
+	if open != '{' && open != '(' {
+		return nil
+	}
+	close := byte('}')
+	if open == '(' {
+		close = ')'
+	}
+	switch entryType {
+	case "comment", "preamble":
+		if open == '{' {
+			if _, err := p.delimited(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "string":
+		p.advance(p.pos + 1)
+		fields, err := p.fields(close)
+		if err != nil {
+			return err
+		}
+		for name, value := range fields {
+			p.macros[name] = value
+		}
+		return nil
+	}
+	p.advance(p.pos + 1)
+	p.skipSpace()
+	key := p.identifier()
+	fields, err := p.fields(close)
+	if err != nil {
+		return err
+	}
+	fn(entryType, key, fields, lineNo)
+	return nil
+}
+
+// Remove braces and common escapes, e.g. from title.
+func bibtexText(value string) string {
+	value = bibtexUnescape(value)
+	value = strings.NewReplacer("{", "", "}", "", "~", " ").Replace(value)
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func bibtexUnescape(value string) string {
+	return strings.NewReplacer(`\_`, "_", `\%`, "%", `\&`, "&", `\#`, "#", `\$`, "$", `\~`, "~").Replace(value)
+}
+
+// CSL-JSON file, e.g. exported by Zotero for Pandoc.
+type CslJsonLinkSource string
+
+var _ TextLinkSource = (*CslJsonLinkSource)(nil)
+
+func (s CslJsonLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ CslJsonLinkSource) Flag() string {
+	return "csl-json"
+}
+
+func (_ CslJsonLinkSource) Clone(src string) TextLinkSource {
+	v := CslJsonLinkSource(src)
+	return &v
+}
+
+type cslItem struct {
+	// Usually string but numbers are allowed as well
+	Id    interface{} `json:"id"`
+	Type  string      `json:"type"`
+	Title string      `json:"title"`
+	URL   string      `json:"URL"`
+	DOI   string      `json:"DOI"`
+}
+
+// Line number of an item is the line where its object starts.
+func (_ CslJsonLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	if token, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if token != json.Delim('[') {
+		return nil, errors.New("array of items expected")
+	}
+	tree := NewTreeChildrenNode(nil)
+	lineNo, offset := 1, 0
+	for decoder.More() {
+		// Offset is before separators preceding the item.
+		start := int(decoder.InputOffset())
+		for start < len(content) && strings.IndexByte(" \t\r\n,", content[start]) >= 0 {
+			start++
+		}
+		lineNo += bytes.Count(content[offset:start], []byte("\n"))
+		offset = start
+		var item cslItem
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		props := &BibEntry{Key: fmt.Sprint(item.Id), Type: item.Type, Title: item.Title, LineNo: lineNo}
+		fields := map[string]string{"url": item.URL, "doi": item.DOI}
+		if node := bibEntryNode(props, fields, lineNo, filter); node != nil {
+			tree.AddChild(node)
+		}
+	}
+	return &tree, nil
+}
+
+func (s CslJsonLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
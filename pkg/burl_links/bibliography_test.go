@@ -0,0 +1,120 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type bibTestLink struct {
+	Entry BibEntry
+	Link  Link
+}
+
+func bibLinks(tree *TreeChildrenNode) []bibTestLink {
+	var retval []bibTestLink
+	for _, child := range tree.Children {
+		entry := child.(*TreeChildrenNode)
+		for _, link := range entry.Children[0].(*TreeLeafNode).Links {
+			retval = append(retval, bibTestLink{*entry.Props.(*BibEntry), *link})
+		}
+	}
+	return retval
+}
+
+func TestBibtexExtract(t *testing.T) {
+	input := `% Exported by Better BibTeX, contact: someone@example.com
+@string{ pub = "Publisher" }
+@comment{ @article{skipped, url = {https://example.com/skipped}} }
+
+@article{smith2020,
+  title = {The {Title} of \& Paper},
+  author = "Smith, John",
+  journal = pub # " Journal",
+  year = 2020,
+  doi = {https://doi.org/10.1000/xyz-123},
+  url = {https://example.com/paper\_1},
+}
+@Misc(arxiv2101,
+  Title = "Preprint",
+  EPrint = {2101.00001},
+  ArchivePrefix = {arXiv}
+)
+@online{both, eprint = {2102.00002}, archiveprefix = {arXiv}, eprinttype = {arxiv}}
+@book{nolinks, title = {No links}}
+`
+	tree, err := BibtexLinkSource("refs.bib").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paper := BibEntry{Key: "smith2020", Type: "article", Title: "The Title of & Paper", LineNo: 5}
+	preprint := BibEntry{Key: "arxiv2101", Type: "misc", Title: "Preprint", LineNo: 13}
+	both := BibEntry{Key: "both", Type: "online", LineNo: 18}
+	expect := []bibTestLink{
+		{paper, Link{URL: "https://example.com/paper_1", LineNo: 5, Property: "url"}},
+		{paper, Link{URL: "doi:10.1000/xyz-123", LineNo: 5, Property: "doi"}},
+		{preprint, Link{URL: "https://arxiv.org/abs/2101.00001", LineNo: 13, Property: "eprint"}},
+		{both, Link{URL: "https://arxiv.org/abs/2102.00002", LineNo: 18, Property: "eprint"}},
+	}
+	if actual := bibLinks(tree); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestBibtexMalformedEntry(t *testing.T) {
+	input := `@article{broken, title = {Unbalanced, url = {https://example.com/broken}
+@misc{ok, url = {https://example.com/ok}}
+@misc{nofield, url https://example.com/nofield}
+@misc{last, url = {https://example.com/last}}
+`
+	tree, err := BibtexLinkSource("refs.bib").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []bibTestLink{
+		{BibEntry{Key: "ok", Type: "misc", LineNo: 2}, Link{URL: "https://example.com/ok", LineNo: 2, Property: "url"}},
+		{BibEntry{Key: "last", Type: "misc", LineNo: 4}, Link{URL: "https://example.com/last", LineNo: 4, Property: "url"}},
+	}
+	if actual := bibLinks(tree); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestCslJsonExtract(t *testing.T) {
+	input := `[
+  {"id": "doe2021", "type": "article-journal", "title": "Paper",
+   "DOI": "10.1000/abc", "URL": "https://example.com/paper"},
+  {"id": 17, "type": "book", "title": "No links"},
+
+  {"id": "web", "type": "webpage", "title": "Page", "URL": "https://example.com/page"}
+]`
+	tree, err := CslJsonLinkSource("refs.json").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := BibEntry{Key: "doe2021", Type: "article-journal", Title: "Paper", LineNo: 2}
+	page := BibEntry{Key: "web", Type: "webpage", Title: "Page", LineNo: 6}
+	expect := []bibTestLink{
+		{entry, Link{URL: "https://example.com/paper", LineNo: 2, Property: "url"}},
+		{entry, Link{URL: "doi:10.1000/abc", LineNo: 2, Property: "doi"}},
+		{page, Link{URL: "https://example.com/page", LineNo: 6, Property: "url"}},
+	}
+	if actual := bibLinks(tree); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
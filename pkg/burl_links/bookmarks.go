@@ -0,0 +1,215 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var reBookmarksToken = regexp.MustCompile(
+	`(?is)<(/?)DL\b[^>]*>|<H3\b[^>]*>(.*?)</H3>|<A\b([^>]*)>(.*?)</A>`)
+var reBookmarksHref = regexp.MustCompile(`(?i)\bHREF[ \t\n]*=[ \t\n]*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+var reHtmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// Folder of bookmarks.
+type BookmarkFolder struct {
+	Title  string `json:"title"`
+	LineNo int    `json:"lineNo,omitempty"`
+}
+
+var _ TreeNodeProps = (*BookmarkFolder)(nil)
+
+func (_ *BookmarkFolder) BurlType() string {
+	return "BookmarkFolder"
+}
+
+// Bookmarks exported in Netscape HTML format by Firefox, Chrome,
+// Pocket, Raindrop, etc. Folders become tree nodes and titles
+// of bookmarks are used as link descriptions.
+type BookmarksHtmlLinkSource string
+
+var _ TextLinkSource = (*BookmarksHtmlLinkSource)(nil)
+
+func (s BookmarksHtmlLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ BookmarksHtmlLinkSource) Flag() string {
+	return "bookmarks-html"
+}
+
+func (_ BookmarksHtmlLinkSource) Clone(src string) TextLinkSource {
+	v := BookmarksHtmlLinkSource(src)
+	return &v
+}
+
+func htmlText(fragment string) string {
+	text := html.UnescapeString(reHtmlTag.ReplaceAllString(fragment, ""))
+	return strings.Join(strings.Fields(text), " ")
+}
+
+func (_ BookmarksHtmlLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	text := string(content)
+	root := &nodeBuilder{}
+	stack := []*nodeBuilder{root}
+	// <H3> is followed by <DL> with its content.
+	var folder *BookmarkFolder
+	lineNo, offset := 1, 0
+	for _, loc := range reBookmarksToken.FindAllStringSubmatchIndex(text, -1) {
+		lineNo += strings.Count(text[offset:loc[0]], "\n")
+		offset = loc[0]
+		top := stack[len(stack)-1]
+		switch {
+		case loc[2] >= 0 && loc[3] > loc[2]:
+			// </DL>
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+			folder = nil
+		case loc[2] >= 0:
+			// <DL>
+			if folder != nil {
+				stack = append(stack, top.addFolder(folder))
+				folder = nil
+			} else if len(stack) > 1 {
+				// Anonymous list, keep links in the current folder
+				stack = append(stack, top)
+			}
+		case loc[4] >= 0:
+			folder = &BookmarkFolder{Title: htmlText(text[loc[4]:loc[5]]), LineNo: lineNo}
+		case loc[6] >= 0:
+			folder = nil
+			match := reBookmarksHref.FindStringSubmatch(text[loc[6]:loc[7]])
+			if match == nil {
+				continue
+			}
+			url := html.UnescapeString(match[1] + match[2] + match[3])
+			if reScheme.MatchString(url) {
+				top.addLink(&Link{
+					URL: url, Description: htmlText(text[loc[8]:loc[9]]), LineNo: lineNo,
+				}, filter)
+			}
+		}
+	}
+	return root.build(), nil
+}
+
+func (s BookmarksHtmlLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
+
+// Bookmarks file of Chrome and Chromium based browsers,
+// e.g. ~/.config/chromium/Default/Bookmarks.
+type BookmarksJsonLinkSource string
+
+var _ TextLinkSource = (*BookmarksJsonLinkSource)(nil)
+
+func (s BookmarksJsonLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ BookmarksJsonLinkSource) Flag() string {
+	return "bookmarks-json"
+}
+
+func (_ BookmarksJsonLinkSource) Clone(src string) TextLinkSource {
+	v := BookmarksJsonLinkSource(src)
+	return &v
+}
+
+type chromeBookmark struct {
+	Type     string            `json:"type"`
+	Name     string            `json:"name"`
+	URL      string            `json:"url"`
+	Children []*chromeBookmark `json:"children"`
+}
+
+// Besides folders, roots may contain other fields,
+// e.g. "sync_transaction_version" in older profiles.
+type chromeBookmarksFile struct {
+	Roots map[string]json.RawMessage `json:"roots"`
+}
+
+// Order of top level folders in browser UI.
+var chromeBookmarkRoots = []string{"bookmark_bar", "other", "synced"}
+
+func (b *chromeBookmark) addTo(folder *nodeBuilder, filter Filter) {
+	switch b.Type {
+	case "url":
+		if reScheme.MatchString(b.URL) {
+			folder.addLink(&Link{URL: b.URL, Description: b.Name}, filter)
+		}
+	case "folder":
+		child := folder.addFolder(&BookmarkFolder{Title: b.Name})
+		for _, item := range b.Children {
+			item.addTo(child, filter)
+		}
+	}
+}
+
+func (_ BookmarksJsonLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	var content chromeBookmarksFile
+	if err := json.NewDecoder(file).Decode(&content); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(content.Roots))
+	for name := range content.Roots {
+		names = append(names, name)
+	}
+	rank := func(name string) int {
+		for i, known := range chromeBookmarkRoots {
+			if name == known {
+				return i
+			}
+		}
+		return len(chromeBookmarkRoots)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if ri, rj := rank(names[i]), rank(names[j]); ri != rj {
+			return ri < rj
+		}
+		return names[i] < names[j]
+	})
+	root := &nodeBuilder{}
+	for _, name := range names {
+		raw := bytes.TrimSpace(content.Roots[name])
+		if len(raw) == 0 || raw[0] != '{' {
+			continue
+		}
+		var folder chromeBookmark
+		if err := json.Unmarshal(raw, &folder); err != nil {
+			return nil, fmt.Errorf("roots.%s: %w", name, err)
+		}
+		folder.addTo(root, filter)
+	}
+	return root.build(), nil
+}
+
+func (s BookmarksJsonLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
@@ -0,0 +1,137 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Folder path and link for every bookmark.
+func bookmarkPaths(tree *TreeChildrenNode) []string {
+	var retval []string
+	var walk func(node TreeBaseNode, path string)
+	walk = func(node TreeBaseNode, path string) {
+		switch n := node.(type) {
+		case *TreeChildrenNode:
+			if folder, ok := n.Props.(*BookmarkFolder); ok {
+				path += folder.Title + "/"
+			}
+			for _, child := range n.Children {
+				walk(child, path)
+			}
+		case *TreeLeafNode:
+			for _, link := range n.Links {
+				retval = append(retval, path+" "+link.URL+" "+link.Description)
+			}
+		}
+	}
+	walk(tree, "")
+	return retval
+}
+
+func TestBookmarksHtml(t *testing.T) {
+	input := `<!DOCTYPE NETSCAPE-Bookmark-file-1>
+<META HTTP-EQUIV="Content-Type" CONTENT="text/html; charset=UTF-8">
+<TITLE>Bookmarks</TITLE>
+<H1>Bookmarks Menu</H1>
+<DL><p>
+    <DT><H3 ADD_DATE="1650000000">Folder</H3>
+    <DL><p>
+        <DT><H3>Subfolder &amp; more</H3>
+        <DL><p>
+            <DT><A HREF="https://example.com/deep?a=1&amp;b=2" ADD_DATE="1650000000">Deep &lt;link&gt;</A>
+        </DL><p>
+        <DT><A HREF="https://example.com/folder">After
+subfolder</A>
+        <DT><H3>Empty</H3>
+        <DL><p>
+            <DT><A HREF="place:type=6">Recent tags</A>
+        </DL><p>
+    </DL><p>
+    <DT><A HREF='https://example.com/top'>Top</A>
+</DL>
+`
+	tree, err := BookmarksHtmlLinkSource("bookmarks.html").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Links precede subfolders
+	expect := []string{
+		" https://example.com/top Top",
+		"Folder/ https://example.com/folder After subfolder",
+		"Folder/Subfolder & more/ https://example.com/deep?a=1&b=2 Deep <link>",
+	}
+	if actual := bookmarkPaths(tree); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%q != %q", actual, expect)
+	}
+	folder := tree.Children[1].(*TreeChildrenNode)
+	if props := folder.Props.(*BookmarkFolder); props.LineNo != 6 {
+		t.Errorf("wrong line of folder %+v", props)
+	}
+	if link := folder.Children[0].(*TreeLeafNode).Links[0]; link.LineNo != 12 {
+		t.Errorf("wrong line of link %+v", link)
+	}
+}
+
+func TestBookmarksJson(t *testing.T) {
+	input := `{
+   "checksum": "0",
+   "roots": {
+      "sync_transaction_version": "12",
+      "other": {
+         "children": [ {
+            "name": "Other",
+            "type": "url",
+            "url": "https://example.com/other"
+         } ],
+         "name": "Other bookmarks",
+         "type": "folder"
+      },
+      "bookmark_bar": {
+         "children": [ {
+            "children": [ {
+               "name": "Nested",
+               "type": "url",
+               "url": "https://example.com/nested"
+            } ],
+            "name": "Folder",
+            "type": "folder"
+         }, {
+            "name": "Bar",
+            "type": "url",
+            "url": "https://example.com/bar"
+         } ],
+         "name": "Bookmarks bar",
+         "type": "folder"
+      }
+   },
+   "version": 1
+}`
+	tree, err := BookmarksJsonLinkSource("Bookmarks").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []string{
+		"Bookmarks bar/ https://example.com/bar Bar",
+		"Bookmarks bar/Folder/ https://example.com/nested Nested",
+		"Other bookmarks/ https://example.com/other Other",
+	}
+	if actual := bookmarkPaths(tree); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%q != %q", actual, expect)
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxnikulin/burl/pkg/burl_url"
+)
+
+// Increment only when format of stored trees or extraction code
+// is changed in a way that makes earlier stored trees obsolete.
+// Changes of settings are detected by cacheOptions.
+const cacheFormatVersion = 8
+
+func init() {
+	// Concrete types that may appear in trees
+	gob.Register(&TreeChildrenNode{})
+	gob.Register(&TreeLeafNode{})
+	gob.Register(&FileProps{})
+	gob.Register(&Heading{})
+	gob.Register(&BookmarkFolder{})
+	gob.Register(&MailMessage{})
+	gob.Register(&BibEntry{})
+	gob.Register(&ExternalNode{})
+}
+
+type cacheEntry struct {
+	Size    int64
+	ModTime time.Time
+	Hash    [sha256.Size]byte
+	Tree    *TreeChildrenNode
+}
+
+type cacheFile struct {
+	Version int
+	Options string
+	Entries map[string]*cacheEntry
+}
+
+// Trees extracted from files stored on disk to avoid parsing
+// of unchanged files when the backend is launched again.
+// Entries are valid if size and modification time of the file
+// are the same or if content has the same hash.
+type LinkCache struct {
+	Path     string
+	mutex    sync.Mutex
+	loaded   map[string]*cacheEntry
+	entries  map[string]*cacheEntry
+	modified bool
+}
+
+// Settings affecting extraction and URL comparison,
+// cache is discarded if they are changed.
+func cacheOptions() string {
+	return fmt.Sprintf("scheme=%s url=%s", reSchemeStr, burl_url.SettingsDigest())
+}
+
+func cacheKey(src TextLinkSource) string {
+	key := src.Flag() + ":" + src.Name()
+	if _, options := UnwrapSource(src); !options.IsDefault() {
+		key += "\x00" + strings.Join(options.Args(), "\x00")
+	}
+	return key
+}
+
+// Load cache from file. Failures are logged and empty cache is returned
+// since it is just an optimization.
+func OpenLinkCache(path string) *LinkCache {
+	cache := &LinkCache{
+		Path:    path,
+		loaded:  map[string]*cacheEntry{},
+		entries: map[string]*cacheEntry{},
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Printf("burl_links.OpenLinkCache: %v", err)
+		}
+		return cache
+	}
+	defer file.Close()
+	var content cacheFile
+	if err := gob.NewDecoder(file).Decode(&content); err != nil {
+		log.Printf("burl_links.OpenLinkCache: %s: %v", path, err)
+		return cache
+	}
+	if content.Version != cacheFormatVersion || content.Options != cacheOptions() {
+		log.Printf("burl_links.OpenLinkCache: %s: ignored since options are changed", path)
+		cache.modified = true
+		return cache
+	}
+	if content.Entries != nil {
+		cache.loaded = content.Entries
+	}
+	return cache
+}
+
+func (c *LinkCache) lookup(key string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entry := c.entries[key]; entry != nil {
+		return entry
+	}
+	return c.loaded[key]
+}
+
+func (c *LinkCache) store(key string, entry *cacheEntry, modified bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = entry
+	if modified {
+		c.modified = true
+	}
+}
+
+// Get tree from cache or extract links from file and store the result.
+// Filter is not supported since it makes tree incomplete.
+// Encrypted files should not be passed here.
+func (c *LinkCache) Extract(src TextLinkSource, info os.FileInfo) (*TreeChildrenNode, error) {
+	key := cacheKey(src)
+	entry := c.lookup(key)
+	if entry != nil && !StampsUnchanged(treeIncludes(entry.Tree)) {
+		entry = nil
+	}
+	if entry != nil && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		c.store(key, entry, false)
+		return entry.Tree, nil
+	}
+	content, err := ioutil.ReadFile(src.Name())
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(content)
+	if entry != nil && entry.Size == int64(len(content)) && entry.Hash == hash {
+		c.store(key, &cacheEntry{entry.Size, info.ModTime(), hash, entry.Tree}, true)
+		return entry.Tree, nil
+	}
+	_, options := UnwrapSource(src)
+	reader, err := decodeFile(src.Name(), ioutil.NopCloser(bytes.NewReader(content)), options)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := ExtractLinksFromReader(src, reader, nil)
+	if closeErr := reader.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return tree, err
+	}
+	c.store(key, &cacheEntry{int64(len(content)), info.ModTime(), hash, tree}, true)
+	return tree, nil
+}
+
+// Write entries used since cache is loaded, so removed files are dropped.
+func (c *LinkCache) Save() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if !c.modified && len(c.entries) == len(c.loaded) {
+		return nil
+	}
+	var buffer bytes.Buffer
+	content := cacheFile{cacheFormatVersion, cacheOptions(), c.entries}
+	if err := gob.NewEncoder(&buffer).Encode(&content); err != nil {
+		return fmt.Errorf("encode link cache: %w", err)
+	}
+	dir := filepath.Dir(c.Path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(dir, filepath.Base(c.Path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	if err := os.Rename(file.Name(), c.Path); err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	c.modified = false
+	c.loaded = c.entries
+	c.entries = map[string]*cacheEntry{}
+	for key, entry := range c.loaded {
+		c.entries[key] = entry
+	}
+	return nil
+}
+
+// Default location of cache file: $XDG_CACHE_HOME/burl/links.cache on Linux.
+func DefaultCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "burl", "links.cache"), nil
+}
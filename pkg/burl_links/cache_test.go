@@ -0,0 +1,109 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxnikulin/burl/pkg/burl_url"
+)
+
+func TestLinkCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cachePath := filepath.Join(dir, "cache", "links.cache")
+	notes := filepath.Join(dir, "notes.org")
+	mtime := time.Now().Add(-time.Hour)
+	// Content of the same size allows to detect usage of stale cache.
+	write := func(content string) os.FileInfo {
+		if err := ioutil.WriteFile(notes, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(notes, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(notes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return info
+	}
+	firstURL := []string{"https://first.example.com/"}
+	secondURL := []string{"https://secnd.example.com/"}
+	extract := func(cache *LinkCache, info os.FileInfo) *LinkIndex {
+		tree, err := cache.Extract(OrgLinkSource(notes), info)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewLinkIndex(tree)
+	}
+
+	info := write("* Heading\n" + firstURL[0] + "\n")
+	cache := OpenLinkCache(cachePath)
+	if extract(cache, info).CountMentions(firstURL) == nil {
+		t.Fatalf("link is not extracted")
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	info = write("* Heading\n" + secondURL[0] + "\n")
+	if extract(OpenLinkCache(cachePath), info).CountMentions(firstURL) == nil {
+		t.Errorf("cached tree should be used for the same size and mtime")
+	}
+
+	mtime = mtime.Add(time.Minute)
+	info = write("* Heading\n" + secondURL[0] + "\n")
+	if extract(OpenLinkCache(cachePath), info).CountMentions(secondURL) == nil {
+		t.Errorf("changed file should be parsed again")
+	}
+
+	defer UpdateRe(SchemeVariants)
+	if err := UpdateRe([]string{"https", "news"}); err != nil {
+		t.Fatal(err)
+	}
+	mtime = mtime.Add(-time.Minute)
+	info = write("* Heading\n" + secondURL[0] + "\n")
+	if extract(OpenLinkCache(cachePath), info).CountMentions(secondURL) == nil {
+		t.Errorf("cache should be discarded when scheme variants are changed")
+	}
+}
+
+func TestLinkCacheOptionsUrlSettings(t *testing.T) {
+	options := cacheOptions()
+	defer func(saved []*burl_url.RewriteRule) { burl_url.UserRewriteRules = saved }(burl_url.UserRewriteRules)
+	rule, err := burl_url.NewRewriteRule(`https://example\.com/(.*)`, "https://example.org/$1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	burl_url.UserRewriteRules = []*burl_url.RewriteRule{rule}
+	if cacheOptions() == options {
+		t.Errorf("cache options should depend on rewrite rules")
+	}
+	burl_url.UserRewriteRules = nil
+	defer func(saved []string) { burl_url.TrackingParams = saved }(burl_url.TrackingParams)
+	burl_url.TrackingParams = append([]string{"ref"}, burl_url.TrackingParams...)
+	if cacheOptions() == options {
+		t.Errorf("cache options should depend on tracking parameters")
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Program used to decrypt files when SourceOptions.Decrypt is set,
+// passphrase is requested by gpg-agent.
+var GpgProgram = "gpg"
+
+// Limit of time for decryption including passphrase prompt,
+// other requests wait while files are read.
+var GpgTimeout = time.Minute
+
+var errEncrypted = errors.New("encrypted file, decryption is not enabled by -decrypt")
+
+// Suffixes of compressed and encrypted files. Format of content is detected
+// by the suffix before them, e.g. "notes.org.gz" is an Org file.
+var CompressedSuffixes = []string{".gz", ".bz2", ".gpg"}
+
+func compressedSuffix(path string) string {
+	for _, suffix := range CompressedSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
+
+// Path without compression and encryption suffixes.
+func StripCompressedSuffix(path string) string {
+	for suffix := compressedSuffix(path); suffix != ""; suffix = compressedSuffix(path) {
+		path = strings.TrimSuffix(path, suffix)
+	}
+	return path
+}
+
+// Links from encrypted files should not be stored in plain text cache.
+func isEncrypted(path string) bool {
+	for suffix := compressedSuffix(path); suffix != ""; suffix = compressedSuffix(path) {
+		if suffix == ".gpg" {
+			return true
+		}
+		path = strings.TrimSuffix(path, suffix)
+	}
+	return false
+}
+
+// Decoded content, closers of all layers are called in reverse order.
+type decodedFile struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (f *decodedFile) Close() error {
+	var retval error
+	for i := len(f.closers) - 1; i >= 0; i-- {
+		if err := f.closers[i].Close(); err != nil && retval == nil {
+			retval = err
+		}
+	}
+	return retval
+}
+
+// Decompress and decrypt raw content of path according to its suffixes,
+// convert text to UTF-8, see newTextReader.
+// Raw is closed when the result is closed or in the case of error.
+func decodeFile(path string, raw io.ReadCloser, options *SourceOptions) (io.ReadCloser, error) {
+	file := &decodedFile{Reader: raw, closers: []io.Closer{raw}}
+	for suffix := compressedSuffix(path); suffix != ""; suffix = compressedSuffix(path) {
+		path = strings.TrimSuffix(path, suffix)
+		switch suffix {
+		case ".gz":
+			reader, err := gzip.NewReader(file.Reader)
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			file.Reader = reader
+			file.closers = append(file.closers, reader)
+		case ".bz2":
+			file.Reader = bzip2.NewReader(file.Reader)
+		case ".gpg":
+			if options == nil || !options.Decrypt {
+				file.Close()
+				return nil, errEncrypted
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), GpgTimeout)
+			output, err := startCommand(
+				ctx, cancel, file.Reader, GpgProgram, "--quiet", "--batch", "--decrypt")
+			if err != nil {
+				file.Close()
+				return nil, err
+			}
+			file.Reader = output
+			file.closers = append(file.closers, output)
+		}
+	}
+	encoding := ""
+	if options != nil {
+		encoding = options.Encoding
+	}
+	text, err := newTextReader(file.Reader, encoding)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	file.Reader = text
+	return file, nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCompressedSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	writer.Write([]byte("* Archived\n[[https://example.com/archived][Archived]]\n"))
+	writer.Close()
+	compressed := filepath.Join(dir, "notes-2019.org.gz")
+	if err := ioutil.WriteFile(compressed, buffer.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := SourceForFile(compressed)
+	if _, ok := src.(OrgLinkSource); !ok {
+		t.Fatalf("Org source expected for %s: %T", compressed, src)
+	}
+	cache := OpenLinkCache(filepath.Join(dir, "links.cache"))
+	snapshot := UpdateFileGroup(nil, []TextLinkSource{src}, nil, cache)
+	if snapshot.Err != nil {
+		t.Fatal(snapshot.Err)
+	}
+	if locations := snapshot.Index.Lookup([]string{"https://example.com/archived"}); len(locations) != 1 {
+		t.Errorf("link from compressed file is not found: %+v", locations)
+	}
+	if !snapshot.HasFile(compressed) {
+		t.Errorf("original file should be allowed for visit")
+	}
+
+	encrypted := filepath.Join(dir, "journal.org.gpg")
+	if err := ioutil.WriteFile(encrypted, []byte("https://example.com/private\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExtractLinksFromFile(SourceForFile(encrypted), nil); err != errEncrypted {
+		t.Errorf("decryption should be disabled by default: %v", err)
+	}
+	// Fake gpg that passes content through.
+	gpg := filepath.Join(dir, "gpg")
+	if err := ioutil.WriteFile(gpg, []byte("#!/bin/sh\nexec cat\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved string) { GpgProgram = saved }(GpgProgram)
+	GpgProgram = gpg
+	decrypted := WithOptions(SourceForFile(encrypted), &SourceOptions{Decrypt: true})
+	snapshot = UpdateFileGroup(nil, []TextLinkSource{decrypted}, nil, cache)
+	if snapshot.Err != nil {
+		t.Fatal(snapshot.Err)
+	}
+	if locations := snapshot.Index.Lookup([]string{"https://example.com/private"}); len(locations) != 1 {
+		t.Errorf("link from encrypted file is not found: %+v", locations)
+	}
+	if entry := cache.lookup(cacheKey(decrypted)); entry != nil {
+		t.Errorf("links from encrypted files must not be cached")
+	}
+
+	// Passphrase prompt is not answered.
+	counter := filepath.Join(dir, "count")
+	script := "#!/bin/sh\necho run >> '" + counter + "'\nexec sleep 5\n"
+	if err := ioutil.WriteFile(gpg, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved time.Duration) { GpgTimeout = saved }(GpgTimeout)
+	GpgTimeout = 100 * time.Millisecond
+	if err := ioutil.WriteFile(encrypted, []byte("https://example.com/other\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(encrypted, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	failed := UpdateFileGroup(snapshot, []TextLinkSource{decrypted}, nil, cache)
+	if failed.Err == nil {
+		t.Fatalf("decryption timeout is not reported")
+	}
+	if again := UpdateFileGroup(failed, []TextLinkSource{decrypted}, nil, cache); again != failed {
+		t.Errorf("failed decryption of unchanged file should not be retried")
+	}
+	if runs, err := ioutil.ReadFile(counter); err != nil || string(runs) != "run\n" {
+		t.Errorf("gpg should run once: %q %v", runs, err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maxnikulin/burl/pkg/burl_fileutil"
+)
+
+// Files found in directories unless SourceOptions.DirInclude is specified.
+var DefaultDirInclude = []string{"*.org", "*.md", "*.txt"}
+
+// Files in each directory with .gitignore-style patterns.
+var DirIgnoreFiles = []string{".gitignore", ".burlignore"}
+
+var errDirNotExpanded = errors.New("directory source must be expanded to files")
+
+// Directory that is recursively scanned for note files every time
+// when files are checked for modification. Hidden files and directories
+// are skipped. Extractor is chosen by file suffix, see SourceForFile.
+// Compressed files are matched by include globs without compression suffix,
+// encrypted ones are skipped unless SourceOptions.Decrypt is set.
+type DirLinkSource string
+
+var _ TextLinkSource = (*DirLinkSource)(nil)
+var _ ExpandableLinkSource = (*DirLinkSource)(nil)
+
+func (s DirLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ DirLinkSource) Flag() string {
+	return "dir"
+}
+
+func (_ DirLinkSource) Clone(src string) TextLinkSource {
+	v := DirLinkSource(src)
+	return &v
+}
+
+func (_ DirLinkSource) Extract(_ io.Reader, _ Filter) (*TreeChildrenNode, error) {
+	return nil, errDirNotExpanded
+}
+
+func (_ DirLinkSource) ExtractSet(_ io.Reader, _ []string, _ *map[string]bool) error {
+	return errDirNotExpanded
+}
+
+// Set of globs for -dir-include and -dir-exclude options. Glob without "/"
+// matches base name, otherwise path relative to the directory.
+type dirGlobs struct {
+	// Matching rules are the same as for ignore files.
+	rules burl_fileutil.IgnoreRules
+}
+
+func compileDirGlobs(globs []string) (*dirGlobs, error) {
+	var set dirGlobs
+	for _, glob := range globs {
+		if err := set.rules.Add(glob, ""); err != nil {
+			return nil, err
+		}
+	}
+	return &set, nil
+}
+
+// Check slash-separated path relative to the directory.
+func (g *dirGlobs) matches(relPath string, isDir bool) bool {
+	return g.rules.Ignored(relPath, isDir)
+}
+
+func (s DirLinkSource) Expand(options *SourceOptions) ([]TextLinkSource, error) {
+	root := string(s)
+	includeGlobs := options.DirInclude
+	if len(includeGlobs) == 0 {
+		includeGlobs = DefaultDirInclude
+	}
+	include, err := compileDirGlobs(includeGlobs)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileDirGlobs(options.DirExclude)
+	if err != nil {
+		return nil, err
+	}
+	var ignore burl_fileutil.IgnoreRules
+	var retval []TextLinkSource
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// Unreadable subdirectory or file removed during scan
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		isDir := info.IsDir()
+		if path == root && !isDir {
+			return errors.New("not a directory")
+		} else if path != root {
+			if strings.HasPrefix(info.Name(), ".") ||
+				exclude.matches(rel, isDir) || ignore.Ignored(rel, isDir) {
+				if isDir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if isDir {
+			for _, name := range DirIgnoreFiles {
+				if err := ignore.AddFile(filepath.Join(path, name), rel); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err != nil || !target.Mode().IsRegular() {
+				return nil
+			}
+		} else if !info.Mode().IsRegular() {
+			return nil
+		}
+		if include.matches(StripCompressedSuffix(rel), false) &&
+			(options.Decrypt || !isEncrypted(rel)) {
+			retval = append(retval, SourceForFile(path))
+		}
+		return nil
+	})
+	if err == nil && retval == nil {
+		err = errors.New("no files matched in directory")
+	}
+	return retval, err
+}
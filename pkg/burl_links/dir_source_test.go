@@ -0,0 +1,83 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDirLinkSourceExpand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"notes.org":            "",
+		"list.txt":             "",
+		"image.png":            "",
+		".hidden.org":          "",
+		".git/config.org":      "",
+		".gitignore":           "drafts/\n*.tmp.org\n!keep.tmp.org\n",
+		"keep.tmp.org":         "",
+		"skip.tmp.org":         "",
+		"drafts/draft.org":     "",
+		"sub/deep/deeper.org":  "",
+		"sub/.burlignore":      "/deep/skipped.txt\n",
+		"sub/deep/skipped.txt": "",
+		"archive/old.org":      "",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expand := func(options *SourceOptions) []string {
+		list, err := ExpandSources([]TextLinkSource{WithOptions(DirLinkSource(dir), options)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, src := range list {
+			inner, _ := UnwrapSource(src)
+			rel, _ := filepath.Rel(dir, src.Name())
+			names = append(names, inner.Flag()+":"+filepath.ToSlash(rel))
+		}
+		return names
+	}
+
+	expected := []string{
+		"org:archive/old.org", "org:keep.tmp.org", "txt:list.txt",
+		"org:notes.org", "org:sub/deep/deeper.org",
+	}
+	if actual := expand(nil); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("default options: %v != %v", expected, actual)
+	}
+
+	expected = []string{"org:keep.tmp.org", "org:notes.org"}
+	options := &SourceOptions{DirInclude: []string{"*.org"}, DirExclude: []string{"archive", "sub/**"}}
+	if actual := expand(options); !reflect.DeepEqual(expected, actual) {
+		t.Errorf("include and exclude: %v != %v", expected, actual)
+	}
+}
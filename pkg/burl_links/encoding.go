@@ -0,0 +1,305 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Convert src to UTF-8 appending result to dst. Number of consumed bytes
+// is returned, incomplete sequence at the end is kept till more data are
+// available unless atEOF is set.
+type textDecodeFunc func(dst, src []byte, atEOF bool) ([]byte, int)
+
+// Characters 0x80-0xFF of single byte encodings.
+type charmap [128]rune
+
+func (m *charmap) decode(dst, src []byte, _ bool) ([]byte, int) {
+	for _, b := range src {
+		if b < 0x80 {
+			dst = append(dst, b)
+		} else {
+			dst = append(dst, string(m[b-0x80])...)
+		}
+	}
+	return dst, len(src)
+}
+
+func makeCharmap(high []rune) *charmap {
+	var m charmap
+	for i := range m {
+		m[i] = rune(0x80 + i)
+	}
+	copy(m[:], high)
+	return &m
+}
+
+var latin1 = makeCharmap(nil)
+
+var windows1252 = makeCharmap([]rune{
+	0x20AC, 0xFFFD, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0xFFFD, 0x017D, 0xFFFD,
+	0xFFFD, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0xFFFD, 0x017E, 0x0178,
+})
+
+var windows1251 = func() *charmap {
+	m := makeCharmap([]rune{
+		0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+		0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+		0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+		0xFFFD, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+		0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+		0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+		0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+		0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	})
+	// Cyrillic letters А-я
+	for i := 0x40; i < 0x80; i++ {
+		m[i] = rune(0x0410 + i - 0x40)
+	}
+	return m
+}()
+
+func decodeUtf8(dst, src []byte, _ bool) ([]byte, int) {
+	return append(dst, src...), len(src)
+}
+
+func utf16Decoder(order binary.ByteOrder) textDecodeFunc {
+	return func(dst, src []byte, atEOF bool) ([]byte, int) {
+		consumed := 0
+		for consumed+2 <= len(src) {
+			r := rune(order.Uint16(src[consumed:]))
+			size := 2
+			if utf16.IsSurrogate(r) {
+				if consumed+4 > len(src) {
+					if !atEOF {
+						break
+					}
+					r = utf8.RuneError
+				} else {
+					r = utf16.DecodeRune(r, rune(order.Uint16(src[consumed+2:])))
+					// Unpaired surrogate, the next unit is decoded separately.
+					if r != utf8.RuneError {
+						size = 4
+					}
+				}
+			}
+			dst = append(dst, string(r)...)
+			consumed += size
+		}
+		if atEOF && consumed < len(src) {
+			dst = append(dst, string(utf8.RuneError)...)
+			consumed = len(src)
+		}
+		return dst, consumed
+	}
+}
+
+// Names of supported encodings, Emacs coding systems are accepted as well.
+var textEncodings = map[string]textDecodeFunc{
+	"utf-8":        decodeUtf8,
+	"us-ascii":     decodeUtf8,
+	"utf-16le":     utf16Decoder(binary.LittleEndian),
+	"utf-16be":     utf16Decoder(binary.BigEndian),
+	"iso-8859-1":   latin1.decode,
+	"windows-1252": windows1252.decode,
+	"windows-1251": windows1251.decode,
+}
+
+var textEncodingAliases = map[string]string{
+	"utf8":                 "utf-8",
+	"utf-8-with-signature": "utf-8",
+	"prefer-utf-8":         "utf-8",
+	"utf-8-emacs":          "utf-8",
+	"utf-8-auto":           "utf-8",
+	"undecided":            "utf-8",
+	"ascii":                "us-ascii",
+	"utf-16":               "utf-16be",
+	"utf-16-le":            "utf-16le",
+	"utf-16-be":            "utf-16be",
+	"latin-1":              "iso-8859-1",
+	"iso-latin-1":          "iso-8859-1",
+	"latin1":               "iso-8859-1",
+	"cp1252":               "windows-1252",
+	"cp1251":               "windows-1251",
+}
+
+// Normalized name of encoding or error if it is not supported.
+// Emacs end of line suffixes ("-unix", "-dos", "-mac") are ignored.
+func lookupTextEncoding(name string) (string, error) {
+	normalized := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "_", "-")
+	for _, eol := range []string{"-unix", "-dos", "-mac"} {
+		normalized = strings.TrimSuffix(normalized, eol)
+	}
+	if alias, ok := textEncodingAliases[normalized]; ok {
+		normalized = alias
+	}
+	if _, ok := textEncodings[normalized]; !ok {
+		return "", fmt.Errorf("unsupported encoding %q", name)
+	}
+	return normalized, nil
+}
+
+// Supported encodings for usage messages.
+func TextEncodingNames() []string {
+	names := make([]string, 0, len(textEncodings))
+	for name := range textEncodings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var textBoms = []struct {
+	Bom      []byte
+	Encoding string
+}{
+	{[]byte{0xEF, 0xBB, 0xBF}, "utf-8"},
+	{[]byte{0xFF, 0xFE}, "utf-16le"},
+	{[]byte{0xFE, 0xFF}, "utf-16be"},
+}
+
+// Emacs file variable on the first line, or on the second one
+// if the first line starts with "#!".
+var reCodingCookie = regexp.MustCompile(`-\*-(?:.*;)?[ \t]*coding:[ \t]*([\w.-]+?)-?[ \t]*(?:;.*)?-\*-`)
+
+// How many bytes are checked for BOM and coding cookie.
+const textDetectSize = 1024
+
+func detectCodingCookie(head []byte) string {
+	for i := 0; i < 2; i++ {
+		line := head
+		if eol := bytes.IndexByte(head, '\n'); eol >= 0 {
+			line, head = head[:eol], head[eol+1:]
+		} else {
+			head = nil
+		}
+		if match := reCodingCookie.FindSubmatch(line); match != nil {
+			return string(match[1])
+		}
+		if !bytes.HasPrefix(line, []byte("#!")) {
+			break
+		}
+	}
+	return ""
+}
+
+// Converts text to UTF-8 and replaces CRLF line endings by LF.
+type textReader struct {
+	reader io.Reader
+	decode textDecodeFunc
+	buffer []byte
+	// Undecoded tail of input
+	in     []byte
+	out    []byte
+	offset int
+	// CR at the end of decoded chunk, it may be followed by LF.
+	cr  bool
+	err error
+}
+
+// Encoding is determined by BOM, by Emacs coding cookie,
+// or defaultEncoding is used, UTF-8 if it is empty.
+func newTextReader(reader io.Reader, defaultEncoding string) (io.Reader, error) {
+	buffered := bufio.NewReaderSize(reader, textDetectSize)
+	head, err := buffered.Peek(textDetectSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	encoding := ""
+	for _, item := range textBoms {
+		if bytes.HasPrefix(head, item.Bom) {
+			encoding = item.Encoding
+			buffered.Discard(len(item.Bom))
+			break
+		}
+	}
+	if cookie := detectCodingCookie(head); encoding == "" && cookie != "" {
+		if _, err := lookupTextEncoding(cookie); err == nil {
+			encoding = cookie
+		} else {
+			// Unlike -encoding, cookies may be intended for other tools.
+			log.Printf("burl_links.newTextReader: coding cookie ignored: %v", err)
+		}
+	}
+	if encoding == "" {
+		encoding = defaultEncoding
+	}
+	if encoding == "" {
+		encoding = "utf-8"
+	}
+	normalized, err := lookupTextEncoding(encoding)
+	if err != nil {
+		return nil, err
+	}
+	return &textReader{
+		reader: buffered,
+		decode: textEncodings[normalized],
+		buffer: make([]byte, 32*1024),
+	}, nil
+}
+
+func (r *textReader) fill() {
+	n, err := r.reader.Read(r.buffer)
+	r.in = append(r.in, r.buffer[:n]...)
+	atEOF := err != nil
+	var out []byte
+	if r.cr {
+		out = append(out, '\r')
+		r.cr = false
+	}
+	out, consumed := r.decode(out, r.in, atEOF)
+	r.in = append(r.in[:0], r.in[consumed:]...)
+	out = bytes.ReplaceAll(out, []byte("\r\n"), []byte("\n"))
+	if !atEOF && len(out) > 0 && out[len(out)-1] == '\r' {
+		out = out[:len(out)-1]
+		r.cr = true
+	}
+	r.out, r.offset, r.err = out, 0, err
+}
+
+func (r *textReader) Read(p []byte) (int, error) {
+	for r.offset == len(r.out) {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+	n := copy(p, r.out[r.offset:])
+	r.offset += n
+	return n, nil
+}
+
+// Convert content of a file read at once, see newTextReader.
+func decodeText(content []byte, defaultEncoding string) ([]byte, error) {
+	reader, err := newTextReader(bytes.NewReader(content), defaultEncoding)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
@@ -0,0 +1,95 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+	"unicode/utf16"
+)
+
+func TestTextReader(t *testing.T) {
+	utf16le := []byte{0xFF, 0xFE}
+	for _, unit := range utf16.Encode([]rune("a\r\n\U0001F600 б\r\n")) {
+		utf16le = append(utf16le, byte(unit), byte(unit>>8))
+	}
+	cases := []struct {
+		Name     string
+		Input    []byte
+		Encoding string
+		Expect   string
+	}{
+		{"utf-8 BOM", []byte("\xEF\xBB\xBFtext\r\nline\r\n"), "windows-1251", "text\nline\n"},
+		{"utf-16le BOM", utf16le, "", "a\n\U0001F600 б\n"},
+		{"cookie", []byte("# -*- mode: org; coding: cp1251-dos -*-\r\n\xcf\xf0\xe8\xe2\xe5\xf2\r\n"),
+			"", "# -*- mode: org; coding: cp1251-dos -*-\nПривет\n"},
+		{"cookie after shebang", []byte("#!/bin/sh\n# -*- coding: latin-1 -*-\ncaf\xe9\n"),
+			"windows-1251", "#!/bin/sh\n# -*- coding: latin-1 -*-\ncafé\n"},
+		{"option", []byte("\x93quoted\x94 \x80\r"), "windows-1252", "“quoted” €\r"},
+		{"default", []byte("plain\r\rtext\n"), "", "plain\r\rtext\n"},
+		{"unknown cookie", []byte("# -*- coding: koi8-r -*-\ncaf\xe9\n"),
+			"iso-8859-1", "# -*- coding: koi8-r -*-\ncafé\n"},
+		{"emacs cookie", []byte("# -*- coding: utf-8-emacs-unix -*-\n\xd0\xb1\n"),
+			"windows-1251", "# -*- coding: utf-8-emacs-unix -*-\nб\n"},
+	}
+	for _, c := range cases {
+		reader, err := newTextReader(iotest.OneByteReader(bytes.NewReader(c.Input)), c.Encoding)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+			continue
+		}
+		actual, err := ioutil.ReadAll(reader)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+		} else if string(actual) != c.Expect {
+			t.Errorf("%s: %q != %q", c.Name, actual, c.Expect)
+		}
+	}
+	if _, err := newTextReader(bytes.NewReader([]byte("text")), "unknown-42"); err == nil {
+		t.Errorf("error expected for unsupported encoding")
+	}
+}
+
+func TestEncodedSourceLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "old.org")
+	content := "* \xc7\xe0\xec\xe5\xf2\xea\xe8\r\n[[https://example.com/][\xd1\xf1\xfb\xeb\xea\xe0]]\r\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src := WithOptions(OrgLinkSource(path), &SourceOptions{Encoding: "windows-1251"})
+	tree, err := ExtractLinksFromFile(src, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var links []*Link
+	ForEachLink(tree, func(link *Link) bool { links = append(links, link); return true })
+	if len(links) != 1 || links[0].Description != "Ссылка" || links[0].LineNo != 2 {
+		t.Errorf("unexpected links: %+v", links)
+	}
+	heading := tree.Children[0].(*TreeChildrenNode).Props.(*Heading)
+	if heading.Title != "Заметки" {
+		t.Errorf("unexpected heading: %q", heading.Title)
+	}
+}
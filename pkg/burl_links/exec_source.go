@@ -0,0 +1,221 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"time"
+)
+
+// Maximal run time of an external extractor.
+var ExecSourceTimeout = 5 * time.Minute
+
+// Command executed by "/bin/sh -c" that writes links to stdout
+// in JSON Lines format described in README.org in this directory.
+// The command is run again when files mentioned in its output are changed.
+type ExecLinkSource string
+
+var _ TextLinkSource = (*ExecLinkSource)(nil)
+var _ OpenableLinkSource = (*ExecLinkSource)(nil)
+
+func (s ExecLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ ExecLinkSource) Flag() string {
+	return "exec-source"
+}
+
+func (_ ExecLinkSource) Clone(src string) TextLinkSource {
+	v := ExecLinkSource(src)
+	return &v
+}
+
+// Node of a tree received from an external extractor.
+type ExternalNode struct {
+	Title  string `json:"title"`
+	File   string `json:"file,omitempty"`
+	LineNo int    `json:"lineNo,omitempty"`
+}
+
+var _ TreeNodeProps = (*ExternalNode)(nil)
+
+func (_ *ExternalNode) BurlType() string {
+	return "ExternalNode"
+}
+
+// Output of the command, stderr is appended to the error
+// returned by Close if the command fails.
+type execOutput struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stderr bytes.Buffer
+}
+
+// Start a command that is killed when ctx is done, cancel is called
+// when its output is closed.
+func startCommand(
+	ctx context.Context, cancel context.CancelFunc, stdin io.Reader, name string, args ...string,
+) (io.ReadCloser, error) {
+	output := &execOutput{cancel: cancel}
+	output.cmd = exec.CommandContext(ctx, name, args...)
+	output.cmd.Stdin = stdin
+	output.cmd.Stderr = &output.stderr
+	stdout, err := output.cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	output.ReadCloser = stdout
+	if err := output.cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return output, nil
+}
+
+func (s ExecLinkSource) Open() (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), ExecSourceTimeout)
+	return startCommand(ctx, cancel, nil, "/bin/sh", "-c", string(s))
+}
+
+func (o *execOutput) Close() error {
+	defer o.cancel()
+	// Unread output must not block the command.
+	io.Copy(ioutil.Discard, o.ReadCloser)
+	err := o.cmd.Wait()
+	if err != nil {
+		if message := bytes.TrimSpace(o.stderr.Bytes()); len(message) > 0 {
+			err = fmt.Errorf("%w: %s", err, message)
+		}
+	}
+	return err
+}
+
+// Line of extractor output.
+type execRecord struct {
+	Type     string `json:"type"`
+	Id       string `json:"id"`
+	Parent   string `json:"parent"`
+	Node     string `json:"node"`
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Descr    string `json:"descr"`
+	File     string `json:"file"`
+	LineNo   int    `json:"lineNo"`
+	Property string `json:"property"`
+	Path     string `json:"path"`
+}
+
+type execParser struct {
+	root   nodeBuilder
+	nodes  map[string]*nodeBuilder
+	files  map[string]bool
+	stamps []FileStamp
+}
+
+func (p *execParser) watch(path string) {
+	if path == "" || p.files[path] {
+		return
+	}
+	p.files[path] = true
+	p.stamps = append(p.stamps, statFile(path))
+}
+
+func (p *execParser) parent(id string) (*nodeBuilder, error) {
+	if id == "" {
+		return &p.root, nil
+	}
+	if node := p.nodes[id]; node != nil {
+		return node, nil
+	}
+	return nil, fmt.Errorf("unknown node %q", id)
+}
+
+func (p *execParser) add(record *execRecord, filter Filter) error {
+	switch record.Type {
+	case "node":
+		if record.Id == "" {
+			return errors.New("node without id")
+		} else if p.nodes[record.Id] != nil {
+			return fmt.Errorf("duplicated node %q", record.Id)
+		}
+		parent, err := p.parent(record.Parent)
+		if err != nil {
+			return err
+		}
+		p.nodes[record.Id] = parent.addFolder(&ExternalNode{
+			Title: record.Title, File: record.File, LineNo: record.LineNo,
+		})
+		p.watch(record.File)
+	case "link":
+		if record.URL == "" {
+			return errors.New("link without url")
+		}
+		parent, err := p.parent(record.Node)
+		if err != nil {
+			return err
+		}
+		parent.addLink(&Link{
+			URL: record.URL, Description: record.Descr, LineNo: record.LineNo,
+			File: record.File, Property: record.Property,
+		}, filter)
+		p.watch(record.File)
+	case "depends":
+		p.watch(record.Path)
+	}
+	// Other types are reserved for extensions of the protocol.
+	return nil
+}
+
+func (_ ExecLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	parser := execParser{nodes: map[string]*nodeBuilder{}, files: map[string]bool{}}
+	scanner := newLineScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var record execRecord
+		err := json.Unmarshal(line, &record)
+		if err == nil {
+			err = parser.add(&record, filter)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	tree := parser.root.build()
+	if len(parser.stamps) > 0 {
+		tree.Props = &FileProps{Includes: parser.stamps}
+	}
+	return tree, nil
+}
+
+func (s ExecLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
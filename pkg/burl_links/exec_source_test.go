@@ -0,0 +1,116 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecSourceExtract(t *testing.T) {
+	input := `{"type": "link", "url": "https://example.com/top"}
+{"type": "node", "id": "1", "title": "Page", "file": "/notes/page.txt", "lineNo": 3}
+
+{"type": "link", "node": "1", "url": "https://example.com/page", "descr": "Page", "file": "/notes/page.txt", "lineNo": 5}
+{"type": "future", "id": "ignored"}
+{"type": "depends", "path": "/notes/db"}
+`
+	tree, err := ExecLinkSource("cmd").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	top := tree.Children[0].(*TreeLeafNode).Links
+	if len(top) != 1 || top[0].URL != "https://example.com/top" {
+		t.Errorf("unexpected top level links: %+v", top)
+	}
+	page := tree.Children[1].(*TreeChildrenNode)
+	if expect := (ExternalNode{"Page", "/notes/page.txt", 3}); *page.Props.(*ExternalNode) != expect {
+		t.Errorf("%+v != %+v", page.Props, expect)
+	}
+	link := page.Children[0].(*TreeLeafNode).Links[0]
+	expect := Link{URL: "https://example.com/page", Description: "Page",
+		File: "/notes/page.txt", LineNo: 5}
+	if !reflect.DeepEqual(*link, expect) {
+		t.Errorf("%+v != %+v", *link, expect)
+	}
+	var watched []string
+	for _, stamp := range treeIncludes(tree) {
+		watched = append(watched, stamp.Path)
+	}
+	if expect := []string{"/notes/page.txt", "/notes/db"}; !reflect.DeepEqual(watched, expect) {
+		t.Errorf("watched files %v != %v", watched, expect)
+	}
+
+	for _, bad := range []string{
+		`{"type": "link", "node": "missed", "url": "https://example.com/"}`,
+		`{"type": "node", "title": "No id"}`,
+		`{"type": "link"`,
+	} {
+		if _, err := ExecLinkSource("cmd").Extract(strings.NewReader(bad), nil); err == nil {
+			t.Errorf("error expected for %s", bad)
+		}
+	}
+}
+
+func TestExecSourceUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	data := filepath.Join(dir, "data")
+	write := func(content string, mtime time.Time) {
+		if err := ioutil.WriteFile(data, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(data, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Now().Add(-time.Hour)
+	write(`{"type": "link", "url": "https://example.com/first"}`+"\n", mtime)
+	command := "cat '" + data + "' && echo '{\"type\": \"depends\", \"path\": \"" + data + "\"}'"
+	list := []TextLinkSource{ExecLinkSource(command)}
+
+	initial := UpdateFileGroup(nil, list, nil, nil)
+	if initial.Err != nil || initial.Tree == nil {
+		t.Fatalf("command output expected: %+v", initial)
+	}
+	if same := UpdateFileGroup(initial, list, nil, nil); same != initial {
+		t.Errorf("command should not be run again if dependencies are unchanged")
+	}
+	if !initial.HasFile(data) || initial.HasFile(command) {
+		t.Errorf("only files from command output may be visited")
+	}
+
+	write(`{"type": "link", "url": "https://example.com/second"}`+"\n", mtime.Add(time.Minute))
+	modified := UpdateFileGroup(initial, list, nil, nil)
+	var urls []string
+	ForEachLink(modified.Tree, func(link *Link) bool { urls = append(urls, link.URL); return true })
+	if expect := []string{"https://example.com/second"}; !reflect.DeepEqual(urls, expect) {
+		t.Errorf("%v != %v", urls, expect)
+	}
+
+	failed := UpdateFileGroup(nil, []TextLinkSource{ExecLinkSource("echo oops >&2; exit 3")}, nil, nil)
+	if failed.Err == nil || !strings.Contains(failed.Err.Error(), "oops") {
+		t.Errorf("stderr of failed command should be reported: %v", failed.Err)
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io"
+	"regexp"
+	"strings"
+)
+
+var reMdAtxHeading = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))??(?:[ \t]+#+)?[ \t]*$`)
+var reMdSetextUnderline = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+var reMdFence = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+var reMdRefDefinition = regexp.MustCompile(`^ {0,3}\[([^\[\]]+)\]:[ \t]*(?:<([^<>\n]*)>|(\S+))(?:[ \t]+(?:"[^"]*"|'[^']*'|\([^)]*\)))?[ \t]*$`)
+var reMdCodeSpan = regexp.MustCompile("(`+)[^`]+?(`+)")
+var reMdFrontMatterUrl = regexp.MustCompile(`(?i)^url:[ \t]*(.*?)[ \t]*$`)
+
+const mdLinkTextStr = `((?:[^\[\]\n]|\[[^\[\]\n]*\])*)`
+
+// Groups: 1 - "!" for images, 2 - inline text, 3 - <destination>,
+// 4 - destination, 5 - "!", 6 - reference text, 7 - label,
+// 8 - "!", 9 - shortcut label, 10 - autolink, 11 - bare scheme, 12 - rest.
+func makeMdLinkRe(schemeStr string) (*regexp.Regexp, error) {
+	inline := `(!?)\[` + mdLinkTextStr + `\]\([ \t]*(?:<([^<>\n]*)>|((?:[^\s()]|\([^\s()]*\))+))` +
+		`(?:[ \t]+(?:"[^"]*"|'[^']*'|\([^)]*\)))?[ \t]*\)`
+	reference := `(!?)\[` + mdLinkTextStr + `\]\[([^\[\]\n]*)\]`
+	shortcut := `(!?)\[([^\[\]\n]+)\]`
+	autolink := `<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^<>\s]*)>`
+	plain := "\\b(" + schemeStr + "):(" + rePlainSuffixStr + ")"
+	return regexp.Compile(strings.Join([]string{inline, reference, shortcut, autolink, plain}, "|"))
+}
+
+// Markdown file, e.g. README or Obsidian note. Links are attributed
+// to ATX ("# Title") and setext (underlined) headings the same way
+// as for Org files. Inline links, reference-style links, autolinks,
+// and bare URLs are recognized, images and fenced code blocks are skipped.
+// The "url:" key of YAML front matter is reported as link describing
+// the file.
+type MarkdownLinkSource string
+
+var _ TextLinkSource = (*MarkdownLinkSource)(nil)
+
+func (s MarkdownLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ MarkdownLinkSource) Flag() string {
+	return "md"
+}
+
+func (_ MarkdownLinkSource) Clone(src string) TextLinkSource {
+	v := MarkdownLinkSource(src)
+	return &v
+}
+
+// Normalized label of reference link, case and white space are ignored.
+func mdLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+type mdParser struct {
+	headingTree
+	re          *regexp.Regexp
+	definitions map[string]string
+	// Labels of reference links
+	used map[string]bool
+}
+
+// Lines besides front matter and fenced code blocks.
+// Since fn is called for line that may be setext heading,
+// the next line is passed as well.
+func forEachMdLine(lines []string, fn func(lineNo int, line string, next string)) {
+	fence := ""
+	start := 0
+	if len(lines) > 0 && strings.TrimRight(lines[0], " \t") == "---" {
+		for i := 1; i < len(lines); i++ {
+			if line := strings.TrimRight(lines[i], " \t"); line == "---" || line == "..." {
+				start = i + 1
+				break
+			}
+		}
+	}
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if match := reMdFence.FindStringSubmatch(line); match != nil {
+			if fence == "" {
+				fence = match[1]
+				continue
+			} else if match[1][0] == fence[0] && len(match[1]) >= len(fence) &&
+				strings.TrimSpace(line[len(match[0]):]) == "" {
+				fence = ""
+				continue
+			}
+		}
+		if fence != "" {
+			continue
+		}
+		next := ""
+		if i+1 < len(lines) {
+			next = lines[i+1]
+		}
+		fn(i+1, line, next)
+	}
+}
+
+// Front matter lines, nil if there is no front matter.
+func mdFrontMatter(lines []string) []string {
+	if len(lines) == 0 || strings.TrimRight(lines[0], " \t") != "---" {
+		return nil
+	}
+	for i := 1; i < len(lines); i++ {
+		if line := strings.TrimRight(lines[i], " \t"); line == "---" || line == "..." {
+			return lines[1:i]
+		}
+	}
+	return nil
+}
+
+func splitMdLines(file io.Reader) ([]string, error) {
+	var lines []string
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func (s MarkdownLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	lines, err := splitMdLines(file)
+	if err != nil {
+		return nil, err
+	}
+	re, err := makeMdLinkRe(reSchemeStr)
+	if err != nil {
+		return nil, err
+	}
+	p := &mdParser{re: re, definitions: map[string]string{}, used: map[string]bool{}}
+	p.headingTree.init(filter)
+	for i, line := range mdFrontMatter(lines) {
+		if match := reMdFrontMatterUrl.FindStringSubmatch(line); match != nil {
+			url := strings.Trim(match[1], `"'`)
+			if reScheme.MatchString(url) {
+				p.add(&Link{URL: url, LineNo: i + 2, Property: "url"})
+			}
+		}
+	}
+	// Reference definitions may follow links.
+	forEachMdLine(lines, func(_ int, line string, _ string) {
+		if match := reMdRefDefinition.FindStringSubmatch(line); match != nil {
+			label := mdLabel(match[1])
+			if _, has := p.definitions[label]; !has {
+				p.definitions[label] = match[2] + match[3]
+			}
+			return
+		}
+		text := mdStripCode(line)
+		for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+			if label, ok := mdRefLabel(text, loc); ok {
+				p.used[mdLabel(label)] = true
+			}
+		}
+	})
+	setext := false
+	forEachMdLine(lines, func(lineNo int, line string, next string) {
+		if setext {
+			// Underline of setext heading
+			setext = false
+			return
+		}
+		if match := reMdAtxHeading.FindStringSubmatch(line); match != nil {
+			p.push(len(match[1]), &Heading{LineNo: lineNo, RawText: match[2], Title: match[2]})
+		} else if strings.TrimSpace(line) != "" {
+			if match := reMdSetextUnderline.FindStringSubmatch(next); match != nil && !reMdFence.MatchString(line) {
+				level := 1
+				if match[1][0] == '-' {
+					level = 2
+				}
+				text := strings.TrimSpace(line)
+				p.push(level, &Heading{LineNo: lineNo, RawText: text, Title: text})
+				setext = true
+			}
+		}
+		if match := reMdRefDefinition.FindStringSubmatch(line); match != nil {
+			label := mdLabel(match[1])
+			if url := match[2] + match[3]; !p.used[label] && reScheme.MatchString(url) {
+				p.add(&Link{URL: url, Description: match[1], LineNo: lineNo})
+			}
+			return
+		}
+		p.scan(mdStripCode(line), lineNo)
+	})
+	return &p.tree, nil
+}
+
+func (p *mdParser) scan(text string, lineNo int) {
+	for _, loc := range p.re.FindAllStringSubmatchIndex(text, -1) {
+		if link := p.matchLink(text, loc); link != nil {
+			link.LineNo = lineNo
+			p.add(link)
+		} else if label, ok := mdRefLabel(text, loc); ok && p.definitions[mdLabel(label)] == "" {
+			// Just text in brackets
+			p.scan(text[loc[0]+1:loc[1]-1], lineNo)
+		}
+	}
+}
+
+// Submatch of makeMdLinkRe and whether the group participates in the match.
+func mdGroup(text string, loc []int, i int) (string, bool) {
+	if loc[2*i] < 0 {
+		return "", false
+	}
+	return text[loc[2*i]:loc[2*i+1]], true
+}
+
+// Label of reference or shortcut link.
+func mdRefLabel(text string, loc []int) (string, bool) {
+	if _, ok := mdGroup(text, loc, 5); ok {
+		if label, _ := mdGroup(text, loc, 7); label != "" {
+			return label, true
+		}
+		// Collapsed "[label][]"
+		return mdGroup(text, loc, 6)
+	}
+	return mdGroup(text, loc, 9)
+}
+
+func (p *mdParser) matchLink(text string, loc []int) *Link {
+	var url, description string
+	if image, ok := mdGroup(text, loc, 1); ok {
+		if image != "" {
+			return nil
+		}
+		description, _ = mdGroup(text, loc, 2)
+		angled, _ := mdGroup(text, loc, 3)
+		plain, _ := mdGroup(text, loc, 4)
+		url = angled + plain
+	} else if label, ok := mdRefLabel(text, loc); ok {
+		if image, _ := mdGroup(text, loc, 5); image != "" {
+			return nil
+		}
+		if image, _ := mdGroup(text, loc, 8); image != "" {
+			return nil
+		}
+		description, _ = mdGroup(text, loc, 6)
+		if description == "" {
+			description = label
+		}
+		url = p.definitions[mdLabel(label)]
+	} else if autolink, ok := mdGroup(text, loc, 10); ok {
+		url = autolink
+	} else {
+		scheme, _ := mdGroup(text, loc, 11)
+		rest, _ := mdGroup(text, loc, 12)
+		url = scheme + ":" + rest
+	}
+	if url == "" || !reScheme.MatchString(url) {
+		return nil
+	}
+	if description == url {
+		description = ""
+	}
+	return &Link{URL: url, Description: description}
+}
+
+// Replace code spans by spaces to ignore links inside them.
+func mdStripCode(line string) string {
+	if !strings.Contains(line, "`") {
+		return line
+	}
+	return reMdCodeSpan.ReplaceAllStringFunc(line, func(span string) string {
+		return strings.Repeat(" ", len(span))
+	})
+}
+
+func (s MarkdownLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
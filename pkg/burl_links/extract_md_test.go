@@ -0,0 +1,94 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownExtract(t *testing.T) {
+	input := "---\n" +
+		`title: Notes
+url: "https://example.com/front"
+---
+Intro <https://example.com/auto> and https://example.com/bare.
+
+# Title #
+[inline](https://example.com/inline "Title") ![image](https://example.com/image.png)
+[Reference][ref] and [collapsed][] and [shortcut], [not a link] to [see https://example.com/inner]
+` + "`https://example.com/code`" + `
+
+Setext heading
+--------------
+` + "```go" + `
+// https://example.com/fenced
+` + "```" + `
+[wrapped][Ref]
+
+[ref]: https://example.com/ref
+[collapsed]: <https://example.com/collapsed>
+[shortcut]: https://example.com/shortcut 'Title'
+[unused]: https://example.com/unused
+`
+	tree, err := MarkdownLinkSource("test.md").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type location struct {
+		Heading string
+		Link
+	}
+	var actual []location
+	var walk func(node TreeBaseNode, heading string)
+	walk = func(node TreeBaseNode, heading string) {
+		switch n := node.(type) {
+		case *TreeChildrenNode:
+			if h, ok := n.Props.(*Heading); ok {
+				heading = h.Title
+			}
+			for _, child := range n.Children {
+				walk(child, heading)
+			}
+		case *TreeLeafNode:
+			for _, link := range n.Links {
+				actual = append(actual, location{heading, *link})
+			}
+		}
+	}
+	walk(tree, "")
+	expect := []location{
+		{"", Link{URL: "https://example.com/front", LineNo: 3, Property: "url"}},
+		{"", Link{URL: "https://example.com/auto", LineNo: 5}},
+		{"", Link{URL: "https://example.com/bare", LineNo: 5}},
+		{"Title", Link{URL: "https://example.com/inline", Description: "inline", LineNo: 8}},
+		{"Title", Link{URL: "https://example.com/ref", Description: "Reference", LineNo: 9}},
+		{"Title", Link{URL: "https://example.com/collapsed", Description: "collapsed", LineNo: 9}},
+		{"Title", Link{URL: "https://example.com/shortcut", Description: "shortcut", LineNo: 9}},
+		{"Title", Link{URL: "https://example.com/inner", LineNo: 9}},
+		{"Setext heading", Link{URL: "https://example.com/ref", Description: "wrapped", LineNo: 17}},
+		{"Setext heading", Link{URL: "https://example.com/unused", Description: "unused", LineNo: 22}},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+	title := tree.Children[1].(*TreeChildrenNode)
+	setext := title.Children[1].(*TreeChildrenNode)
+	if title.Props.(*Heading).Level != 1 || setext.Props.(*Heading).Level != 2 {
+		t.Errorf("setext heading should be nested: %+v %+v", title.Props, setext.Props)
+	}
+}
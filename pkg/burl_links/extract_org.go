@@ -16,7 +16,6 @@
 package burl_links
 
 import (
-	"bufio"
 	"errors"
 	"fmt"
 	"io"
@@ -30,7 +29,12 @@ var reHeading = regexp.MustCompile(`^(\*+)\s+(\S(?:.*\S)?)?$`)
 // "mid": mail messages, absent in default Org configuration, see
 // RFC 2392 - Content-ID and Message-ID Uniform Resource Locators
 // https://datatracker.ietf.org/doc/html/rfc2392.html
-var SchemeVariants []string = []string{"doi", "https?", "mid"}
+// "news", "notmuch", "gnus": other references to mail messages.
+// "arxiv", "isbn", "pmid": identifiers that are equivalent
+// to URLs of their resolvers, see burl_url.Canonical.
+var SchemeVariants []string = []string{
+	"doi", "https?", "mid", "news", "notmuch", "gnus", "arxiv", "isbn", "pmid",
+}
 var reSchemeStr = MakeSchemeReStr(SchemeVariants)
 var reScheme = regexp.MustCompile("^" + reSchemeStr + ":")
 var reBracketStr = "\\[\\[((?:[^\\]\\[]|\\\\(?:\\\\\\\\)*[\\]\\[]|\\\\+[^\\]\\[])+)](?:\\[((?:.|\n)+?)\\])?\\]"
@@ -72,9 +76,14 @@ func UpdateRe(schemeVariants []string) error {
 	if err != nil {
 		return err
 	}
+	reTxtUrlSchemeTmp, err := regexp.Compile(makeTxtUrlSchemeReStr(schemeStr))
+	if err != nil {
+		return err
+	}
 	reSchemeStr = schemeStr
 	reScheme = reSchemeTmp
 	reLink = reLinkTmp
+	reTxtUrlScheme = reTxtUrlSchemeTmp
 	return nil
 }
 
@@ -84,6 +93,7 @@ var reSetPrefix = regexp.MustCompile("^(?:(?i)[a-z][a-z0-9]*(?:[-+][a-z0-9]+)*)(
 type OrgLinkSource string
 
 var _ TextLinkSource = (*OrgLinkSource)(nil)
+var _ OptionsExtractor = (*OrgLinkSource)(nil)
 
 func (s OrgLinkSource) Name() string {
 	return string(s)
@@ -101,6 +111,21 @@ func (_ OrgLinkSource) Clone(src string) TextLinkSource {
 type Heading struct {
 	LineNo  int    `json:"lineNo"`
 	RawText string `json:"rawText"`
+	// Included file, empty for headings from the file itself.
+	File string `json:"file,omitempty"`
+	// Fields parsed from RawText and from the property drawer.
+	Level    int    `json:"level"`
+	Todo     string `json:"todo,omitempty"`
+	Done     bool   `json:"done,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	// COMMENT keyword, it is not a part of title.
+	Commented bool     `json:"commented,omitempty"`
+	Title     string   `json:"title"`
+	Tags      []string `json:"tags,omitempty"`
+	// Tags of ancestors and #+FILETAGS.
+	InheritedTags []string `json:"inheritedTags,omitempty"`
+	CustomId      string   `json:"customId,omitempty"`
+	Id            string   `json:"id,omitempty"`
 }
 
 var _ TreeNodeProps = (*Heading)(nil)
@@ -112,62 +137,33 @@ func (_ *Heading) BurlType() string {
 func OrgLinkMatchIsUrl(match []string) *Link {
 	if len(match[1]) > 0 {
 		if reScheme.MatchString(match[1]) {
-			return &Link{match[1], match[2], 0}
+			return &Link{URL: match[1], Description: match[2]}
 		} else {
 			return nil
 		}
 	} else if len(match[3]) > 0 {
-		return &Link{match[3] + ":" + match[4], "", 0}
+		return &Link{URL: match[3] + ":" + match[4]}
 	} else if len(match[5]) > 0 {
-		return &Link{match[5] + ":" + match[6], "", 0}
+		return &Link{URL: match[5] + ":" + match[6]}
 	}
 	log.Printf("burl_links.OrgLinkMatchIsUrl: something wrong '%v'", match[0])
 	return nil
 }
 
-func (_ OrgLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
-	headings := make([]*Heading, 0, 10)
-	tree := NewTreeChildrenNode(nil)
-	treeNodes := make([]*TreeChildrenNode, 1, cap(headings)+1)
-	treeNodes[0] = &tree
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
-	lineNo := 0
-	for scanner.Scan() {
-		line := scanner.Text()
-		lineNo++
-		if matchHeading := reHeading.FindStringSubmatch(line); matchHeading != nil {
-			level := len(matchHeading[1])
-			if level > cap(headings) {
-				level = cap(headings) - 1
-			}
-			headings = headings[0 : level-1]
-			if len(treeNodes) >= level {
-				treeNodes = treeNodes[0:level]
-			}
-			for i := len(headings); i < level-1; i++ {
-				headings = append(headings, nil)
-			}
-			h := &Heading{lineNo, matchHeading[2]}
-			headings = append(headings, h)
-		}
-		if matchArray := reLink.FindAllStringSubmatch(line, -1); matchArray != nil {
-			for _, match := range matchArray {
-				if link := OrgLinkMatchIsUrl(match); link != nil &&
-					(filter == nil || filter(link)) {
-					for i := len(treeNodes) - 1; i < len(headings); i++ {
-						newNode := NewTreeChildrenNode(headings[i])
-						treeNodes = append(treeNodes, &newNode)
-						treeNodes[i].AddChild(&newNode)
-					}
-					tip := treeNodes[len(treeNodes)-1]
-					link.LineNo = lineNo
-					tip.AddLink(link)
-				}
-			}
-		}
+func (s OrgLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	return s.ExtractWithOptions(file, filter, &DefaultSourceOptions)
+}
+
+func (s OrgLinkSource) ExtractWithOptions(
+	file io.Reader, filter Filter, options *SourceOptions,
+) (*TreeChildrenNode, error) {
+	parser := newOrgParser(string(s), filter, options)
+	err := parser.parse(file, "", 0)
+	parser.finish()
+	if len(parser.includes) > 0 {
+		parser.tree.Props = &FileProps{Path: string(s), Includes: parser.includes}
 	}
-	return &tree, scanner.Err()
+	return &parser.tree, err
 }
 
 func MakeLinkSetBase(filters []string) (string, error) {
@@ -191,8 +187,7 @@ func MakeLinkSetBase(filters []string) (string, error) {
 
 // TODO: buld filter regexp ones for several files.
 func (_ OrgLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
+	scanner := newLineScanner(file)
 	base, err := MakeLinkSetBase(filters)
 	if err != nil {
 		return err
@@ -16,10 +16,9 @@
 package burl_links
 
 import (
-	"bufio"
 	"io"
-	"log"
 	"regexp"
+	"strings"
 )
 
 type UrlRecord struct {
@@ -44,54 +43,61 @@ func (_ TxtLinkSource) Clone(src string) TextLinkSource {
 }
 
 func (_ TxtLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
+	scanner := newLineScanner(file)
 	lineNo := 0
 	tree := NewTreeChildrenNode(nil)
-	re := regexp.MustCompile(UrlPatternFull)
 	for scanner.Scan() {
-		line := scanner.Bytes()
 		lineNo++
-		for len(line) != 0 {
-			advance, token, err := bufio.ScanWords(line, true)
-			if err != nil && err != bufio.ErrFinalToken {
-				return &tree, err
-			}
-			if matchArray := re.FindAllStringSubmatch(string(token), -1); matchArray != nil {
-				for _, match := range matchArray {
-					if MatchIsUrl(match) {
-						link := &Link{match[0], "", lineNo}
-						if filter != nil && !filter(link) {
-							continue
-						}
-						tree.AddLink(link)
-					}
-				}
+		for _, url := range findTextUrls(scanner.Text()) {
+			link := &Link{URL: url, LineNo: lineNo}
+			if filter != nil && !filter(link) {
+				continue
 			}
-			if err == bufio.ErrFinalToken {
+			tree.AddLink(link)
+		}
+	}
+	return &tree, scanner.Err()
+}
+
+// URLs found in plain text as by TxtLinkSource.Extract.
+// Iteration over URLs in the current line stops when cb returns false.
+func ExtractTextUrls(cb func(url string) bool, file io.Reader) error {
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		for _, url := range findTextUrls(scanner.Text()) {
+			if !cb(url) {
 				break
 			}
-			line = line[advance:]
 		}
 	}
-	return &tree, scanner.Err()
+	return scanner.Err()
 }
 
+// Longer words, e.g. minified data, are skipped by ExtractUrls since regexp
+// matching time grows faster than linearly with word length.
+var txtMaxWordLength = 8192
+
 // cb return value is likely useless. It was conceived to break iterations earlier,
 // but it is necessary to check all items to get best match.
+//
+// Deprecated: match contains submatches of UrlPatternFull that misses
+// query and fragment, use ExtractTextUrls.
 func ExtractUrls(cb func(match []string) bool, file io.Reader) error {
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanWords)
+	scanner := newLineScanner(file)
 
 	re := regexp.MustCompile(UrlPatternFull)
 
 	for scanner.Scan() {
-		word := scanner.Text()
-		if matchArray := re.FindAllStringSubmatch(word, -1); matchArray != nil {
-			for _, match := range matchArray {
-				if MatchIsUrl(match) {
-					if !cb(match) {
-						break
+		for _, word := range strings.Fields(scanner.Text()) {
+			if len(word) > txtMaxWordLength {
+				continue
+			}
+			if matchArray := re.FindAllStringSubmatch(word, -1); matchArray != nil {
+				for _, match := range matchArray {
+					if MatchIsUrl(match) {
+						if !cb(match) {
+							break
+						}
 					}
 				}
 			}
@@ -100,40 +106,27 @@ func ExtractUrls(cb func(match []string) bool, file io.Reader) error {
 	return scanner.Err()
 }
 
-// TODO: replace Org regexp to something more general
+// Unlike Extract, schemes are taken from filters, so links with schemes
+// missed in SchemeVariants are found as well.
 func (_ TxtLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
-	scanner := bufio.NewScanner(file)
-	scanner.Split(bufio.ScanLines)
 	base, err := MakeLinkSetBase(filters)
 	if err != nil {
 		return err
 	}
-	rePlain := "\\b(" + base + rePlainSuffixStr + ")"
-	reSetLink, err := regexp.Compile(rePlain)
+	reStart, err := regexp.Compile(`\b` + base)
 	if err != nil {
 		return err
 	}
-	reBracketPrefix, err := regexp.Compile("^" + base)
+	rePrefix, err := regexp.Compile("^" + base)
 	if err != nil {
 		return err
 	}
+	scanner := newLineScanner(file)
 	for scanner.Scan() {
-		line := scanner.Text()
-		matchArray := reSetLink.FindAllStringSubmatch(line, -1)
-		if matchArray == nil {
-			continue
-		}
-		for _, match := range matchArray {
-			if bracket := match[1]; len(bracket) > 0 {
-				if reBracketPrefix.MatchString(bracket) {
-					(*result)[bracket] = true
-				}
-			} else if angle := match[3]; len(angle) > 0 {
-				(*result)[angle] = true
-			} else if plain := match[4]; len(plain) > 0 {
-				(*result)[plain] = true
-			} else {
-				log.Printf("burl_links.TxtLinkSource.ExtractSet: internal error '%v'", match[0])
+		for _, url := range scanTextUrls(scanner.Text(), reStart) {
+			// Trailing punctuation of the prefix might be stripped.
+			if rePrefix.MatchString(url) {
+				(*result)[url] = true
 			}
 		}
 	}
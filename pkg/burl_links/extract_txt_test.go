@@ -22,7 +22,7 @@ import (
 )
 
 func TestTxtExtractSetCases(t *testing.T) {
-	// Plain text regexp is general enough to recognize URLs
+	// findTextUrls is general enough to recognize URLs
 	// in angled or bracketed Org links.
 	for _, props := range orgExtractSetCases {
 		expect := map[string]bool{}
@@ -42,3 +42,50 @@ func TestTxtExtractSetCases(t *testing.T) {
 		})
 	}
 }
+
+func TestTxtExtractLongLine(t *testing.T) {
+	input := "https://example.com/first\n" + strings.Repeat("x", 200*1024) +
+		" https://example.com/long\r\nhttps://example.com/last"
+	tree, err := TxtLinkSource("test").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "https://example.com/first", LineNo: 1},
+		{URL: "https://example.com/long", LineNo: 2},
+		{URL: "https://example.com/last", LineNo: 3},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestTxtExtractSetNotIndexedScheme(t *testing.T) {
+	input := "see ftp://example.com/pub/. and (https://example.com/x)\nftp:"
+	actual := map[string]bool{}
+	if err := TxtLinkSource("test").ExtractSet(strings.NewReader(input), []string{"ftp"}, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if expect := map[string]bool{"ftp://example.com/pub/": true}; !reflect.DeepEqual(expect, actual) {
+		t.Errorf("%v != %v", expect, actual)
+	}
+}
+
+func TestExtractTextUrls(t *testing.T) {
+	var actual []string
+	err := ExtractTextUrls(func(url string) bool {
+		actual = append(actual, url)
+		return true
+	}, strings.NewReader("see https://example.com/s?q=x#frag.\nmid:abc@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"https://example.com/s?q=x#frag", "mid:abc@example.com"}; !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%q != %q", actual, expect)
+	}
+}
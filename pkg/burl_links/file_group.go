@@ -0,0 +1,221 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"os"
+	"time"
+)
+
+// Links extracted from a single source and file status
+// that allows to detect modification.
+type SourceState struct {
+	Source  TextLinkSource
+	Tree    *TreeChildrenNode
+	Size    int64
+	ModTime time.Time
+	Err     error
+}
+
+// Size and modification time to detect changes of a file.
+// Zero values are used for missed files.
+type FileStamp struct {
+	Path    string
+	Size    int64
+	ModTime time.Time
+}
+
+func statFile(path string) FileStamp {
+	stamp := FileStamp{Path: path}
+	if info, err := os.Stat(path); err == nil {
+		stamp.Size = info.Size()
+		stamp.ModTime = info.ModTime()
+	}
+	return stamp
+}
+
+// Check whether stat of files gives the same results.
+func StampsUnchanged(stamps []FileStamp) bool {
+	for _, stamp := range stamps {
+		current := statFile(stamp.Path)
+		if current.Size != stamp.Size || !current.ModTime.Equal(stamp.ModTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// Files besides the source itself that affect the tree.
+func treeIncludes(tree *TreeChildrenNode) []FileStamp {
+	if tree == nil {
+		return nil
+	}
+	if props, ok := tree.Props.(*FileProps); ok {
+		return props.Includes
+	}
+	return nil
+}
+
+// Failed decryption is not retried until the file is modified
+// to avoid repeated passphrase prompts.
+func (s *SourceState) Unchanged(info os.FileInfo) bool {
+	return (s.Err == nil || isEncrypted(s.Source.Name())) && s.Size == info.Size() && s.ModTime.Equal(info.ModTime()) &&
+		StampsUnchanged(treeIncludes(s.Tree))
+}
+
+// Result of reading of a group of files. It must not be modified
+// after creation, so it can be shared by concurrent requests.
+// Use UpdateFileGroup to get a fresh variant.
+type FileGroupSnapshot struct {
+	Files []*SourceState
+	// Links from successfully read files, nil if there are no links at all.
+	Tree  *TreeChildrenNode
+	Index *LinkIndex
+	// SourceErrors for failed files or nil
+	Err error
+	// To detect changes of unreadable directories
+	expandMessage string
+}
+
+func readSourceState(src TextLinkSource, info os.FileInfo, filter Filter, cache *LinkCache) *SourceState {
+	state := &SourceState{Source: src}
+	if info != nil {
+		state.Size = info.Size()
+		state.ModTime = info.ModTime()
+	}
+	if cache != nil && filter == nil && info != nil && !isEncrypted(src.Name()) {
+		state.Tree, state.Err = cache.Extract(src, info)
+	} else {
+		state.Tree, state.Err = ExtractLinksFromFile(src, filter)
+	}
+	if state.Err != nil {
+		state.Err = sourceError(src, state.Err)
+	}
+	return state
+}
+
+// Check whether file belongs to the group.
+func (s *FileGroupSnapshot) HasFile(name string) bool {
+	for _, state := range s.Files {
+		if state.Source.Name() == name && IsFileSource(state.Source) {
+			return true
+		}
+		for _, include := range treeIncludes(state.Tree) {
+			if include.Path == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Expand directories and stat files from list,
+// read only ones modified since prev snapshot
+// was obtained. Files are parsed concurrently. Unchanged subtrees are shared
+// with prev. Files that failed to read are tried again, except encrypted
+// ones that are not modified. If nothing is changed, prev is returned.
+// Stdin ("-") is read once, OpenableLinkSource is read again only
+// when its include files are changed.
+// Optional cache is used when filter is nil.
+func UpdateFileGroup(
+	prev *FileGroupSnapshot, list []TextLinkSource, filter Filter, cache *LinkCache,
+) *FileGroupSnapshot {
+	list, expandErr := ExpandSources(list)
+	prevStates := map[string]*SourceState{}
+	if prev != nil {
+		for _, state := range prev.Files {
+			prevStates[state.Source.Name()] = state
+		}
+	}
+	modified := prev == nil || len(prev.Files) != len(list)
+	files := make([]*SourceState, len(list))
+	infos := make([]os.FileInfo, len(list))
+	toRead := make([]int, 0, len(list))
+	for i, src := range list {
+		name := src.Name()
+		old := prevStates[name]
+		files[i] = old
+		if name == "-" || !IsFileSource(src) {
+			if old == nil || old.Err != nil ||
+				(name != "-" && !StampsUnchanged(treeIncludes(old.Tree))) {
+				toRead = append(toRead, i)
+			}
+			continue
+		}
+		info, err := os.Stat(name)
+		switch {
+		case err != nil:
+			// Avoid rebuilding of index if file is still missed.
+			if old == nil || old.Tree != nil || old.Err == nil || old.Err.Error() != err.Error() {
+				files[i] = &SourceState{Source: src, Err: err}
+			}
+		case old == nil || !old.Unchanged(info):
+			infos[i] = info
+			toRead = append(toRead, i)
+		}
+		if !modified && prev.Files[i] != files[i] {
+			modified = true
+		}
+	}
+	if len(toRead) > 0 {
+		modified = true
+		parallelFor(len(toRead), func(j int) {
+			i := toRead[j]
+			var fileCache *LinkCache
+			if infos[i] != nil {
+				fileCache = cache
+			}
+			files[i] = readSourceState(list[i], infos[i], filter, fileCache)
+		})
+	}
+	var failures SourceErrors
+	expandMessage := ""
+	if expandErr != nil {
+		failures = expandErr.(SourceErrors)
+		expandMessage = expandErr.Error()
+	}
+	if !modified && prev.expandMessage == expandMessage {
+		return prev
+	}
+	snapshot := &FileGroupSnapshot{Files: files, expandMessage: expandMessage}
+	props := &FileGroupProps{}
+	for _, err := range failures {
+		props.Errors = append(props.Errors, err.Error())
+	}
+	group := NewTreeChildrenNode(props)
+	for _, state := range files {
+		if state.Tree != nil {
+			group.AppendChild(state.Tree)
+		}
+		if state.Err != nil {
+			failures = append(failures, state.Err)
+			props.Errors = append(props.Errors, state.Err.Error())
+		}
+	}
+	if !group.Empty() {
+		snapshot.Tree = &group
+	}
+	if failures != nil {
+		snapshot.Err = failures
+	}
+	if snapshot.Tree != nil {
+		snapshot.Index = NewLinkIndex(snapshot.Tree)
+	} else {
+		// Avoid typed nil interface.
+		snapshot.Index = NewLinkIndex(nil)
+	}
+	return snapshot
+}
@@ -0,0 +1,77 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUpdateFileGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	first := filepath.Join(dir, "first.org")
+	second := filepath.Join(dir, "second.org")
+	write := func(name, content string, mtime time.Time) {
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Now().Add(-time.Hour)
+	write(first, "* First\nhttps://first.example.com/\n", mtime)
+	list := []TextLinkSource{OrgLinkSource(first), OrgLinkSource(second)}
+
+	initial := UpdateFileGroup(nil, list, nil, nil)
+	if initial.Err == nil || initial.Tree == nil || len(initial.Tree.Children) != 1 {
+		t.Fatalf("missed file should be reported along with the read one: %+v", initial)
+	}
+
+	write(second, "* Second\nhttps://second.example.com/\n", mtime)
+	retried := UpdateFileGroup(initial, list, nil, nil)
+	if retried.Err != nil || len(retried.Tree.Children) != 2 {
+		t.Fatalf("failed file should be read again: %+v", retried)
+	}
+	if retried.Files[0] != initial.Files[0] {
+		t.Errorf("unchanged file should not be read again")
+	}
+	if same := UpdateFileGroup(retried, list, nil, nil); same != retried {
+		t.Errorf("snapshot should be reused if files are not modified")
+	}
+
+	write(first, "* First\nhttps://first.example.com/\nhttps://added.example.com/\n",
+		mtime.Add(time.Minute))
+	modified := UpdateFileGroup(retried, list, nil, nil)
+	if modified == retried || modified.Files[0] == retried.Files[0] {
+		t.Fatalf("modified file should be read again")
+	}
+	if modified.Files[1] != retried.Files[1] {
+		t.Errorf("unchanged file should be shared between snapshots")
+	}
+	count := 0
+	ForEachLink(modified.Tree, func(_ *Link) bool { count++; return true })
+	if count != 3 {
+		t.Errorf("expected 3 links in updated tree, got %d", count)
+	}
+}
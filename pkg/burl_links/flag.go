@@ -17,6 +17,10 @@ package burl_links
 
 import (
 	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 )
 
@@ -27,10 +31,11 @@ var MixedSrcNames MixedSrcTypeSlice
 type MixedSrcTypeProxy struct {
 	target  *MixedSrcTypeSlice
 	factory func(value string) TextLinkSource
+	options *SourceOptions
 }
 
 func NewMixedSrcTypeProxyPtr(target *MixedSrcTypeSlice, factory func(value string) TextLinkSource) *MixedSrcTypeProxy {
-	return &MixedSrcTypeProxy{target, factory}
+	return &MixedSrcTypeProxy{target, factory, nil}
 }
 
 func (MixedSrcTypeProxy) String() string {
@@ -39,10 +44,141 @@ func (MixedSrcTypeProxy) String() string {
 
 // TODO check whether not empty and a readable regular file
 func (p *MixedSrcTypeProxy) Set(value string) error {
-	*p.target = append(*p.target, p.factory(value))
+	src := p.factory(value)
+	if p.options != nil {
+		src = WithOptions(src, p.options.Copy())
+	}
+	*p.target = append(*p.target, src)
+	return nil
+}
+
+// Sticky flag for list of values, "" clears the list.
+type sourceOptionsSliceFlag struct {
+	slice *[]string
+	// Valid values if not nil.
+	allowed []string
+}
+
+func (sourceOptionsSliceFlag) String() string {
+	return "FIXME: this is a proxy, value should be accessed directly"
+}
+
+func (f sourceOptionsSliceFlag) Set(value string) error {
+	if value == "" {
+		*f.slice = nil
+		return nil
+	}
+	if f.allowed != nil {
+		valid := false
+		for _, allowed := range f.allowed {
+			if value == allowed {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("%q is not one of %s", value, strings.Join(f.allowed, ", "))
+		}
+	}
+	*f.slice = append(*f.slice, value)
+	return nil
+}
+
+// Sticky boolean flag.
+type sourceOptionsBoolFlag struct {
+	value *bool
+}
+
+func (sourceOptionsBoolFlag) String() string {
+	return "FIXME: this is a proxy, value should be accessed directly"
+}
+
+func (f sourceOptionsBoolFlag) Set(value string) error {
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*f.value = v
+	return nil
+}
+
+func (sourceOptionsBoolFlag) IsBoolFlag() bool {
+	return true
+}
+
+// Sticky flag for a text encoding, it is validated when parsed.
+type sourceOptionsEncodingFlag struct {
+	value *string
+}
+
+func (sourceOptionsEncodingFlag) String() string {
+	return "FIXME: this is a proxy, value should be accessed directly"
+}
+
+func (f sourceOptionsEncodingFlag) Set(value string) error {
+	if value == "" {
+		*f.value = ""
+		return nil
+	}
+	normalized, err := lookupTextEncoding(value)
+	if err != nil {
+		return err
+	}
+	*f.value = normalized
+	return nil
+}
+
+// Sticky flag for a file name, it is converted to absolute path
+// since it may be used in a generated wrapper script.
+type sourceOptionsPathFlag struct {
+	path *string
+}
+
+func (sourceOptionsPathFlag) String() string {
+	return "FIXME: this is a proxy, value should be accessed directly"
+}
+
+func (f sourceOptionsPathFlag) Set(value string) error {
+	if value == "" {
+		*f.path = ""
+		return nil
+	}
+	path, err := expandHome(value)
+	if err != nil {
+		return err
+	}
+	if *f.path, err = filepath.Abs(path); err != nil {
+		return err
+	}
 	return nil
 }
 
+// Source for a file according to its suffix, plain text by default.
+// Used for positional arguments and files found in directories.
+var SuffixSources = []struct {
+	Suffix  string
+	Factory func(string) TextLinkSource
+}{
+	{".org", func(path string) TextLinkSource { return OrgLinkSource(path) }},
+	{".txt", func(path string) TextLinkSource { return TxtLinkSource(path) }},
+	{".md", func(path string) TextLinkSource { return MarkdownLinkSource(path) }},
+	{".markdown", func(path string) TextLinkSource { return MarkdownLinkSource(path) }},
+	{".mbox", func(path string) TextLinkSource { return MboxLinkSource(path) }},
+	{".eml", func(path string) TextLinkSource { return MailMessageLinkSource(path) }},
+	{".bib", func(path string) TextLinkSource { return BibtexLinkSource(path) }},
+}
+
+// Suffixes of compressed and encrypted files are ignored.
+func SourceForFile(path string) TextLinkSource {
+	stripped := StripCompressedSuffix(path)
+	for _, item := range SuffixSources {
+		if strings.HasSuffix(stripped, item.Suffix) {
+			return item.Factory(path)
+		}
+	}
+	return TxtLinkSource(path)
+}
+
 func AddSourceFlags(slice *MixedSrcTypeSlice, flagSet *flag.FlagSet) {
 	if flagSet == nil {
 		flagSet = flag.CommandLine
@@ -53,26 +189,84 @@ func AddSourceFlags(slice *MixedSrcTypeSlice, flagSet *flag.FlagSet) {
 		}
 		slice = &MixedSrcNames
 	}
+	options := &SourceOptions{}
+	addSource := func(name string, factory func(string) TextLinkSource, usage string) {
+		flagSet.Var(&MixedSrcTypeProxy{slice, factory, options}, name, usage)
+	}
 
-	flag.Var(NewMixedSrcTypeProxyPtr(
-		slice,
-		func(value string) TextLinkSource { return TxtLinkSource(value) }),
-		"txt", "Process `FILE` as plain text file (multiple)")
-	flag.Var(NewMixedSrcTypeProxyPtr(
-		slice,
-		func(value string) TextLinkSource { return OrgLinkSource(value) }),
-		OrgLinkSource("").Flag(), "Process `FILE` as Emacs Org Mode file (multiple)")
+	addSource("txt",
+		func(value string) TextLinkSource { return TxtLinkSource(value) },
+		"Process `FILE` as plain text file (multiple)")
+	addSource(OrgLinkSource("").Flag(),
+		func(value string) TextLinkSource { return OrgLinkSource(value) },
+		"Process `FILE` as Emacs Org Mode file (multiple)")
+	addSource(MarkdownLinkSource("").Flag(),
+		func(value string) TextLinkSource { return MarkdownLinkSource(value) },
+		"Process `FILE` as Markdown file (multiple)")
+	addSource(BookmarksHtmlLinkSource("").Flag(),
+		func(value string) TextLinkSource { return BookmarksHtmlLinkSource(value) },
+		"Process `FILE` as bookmarks exported to HTML (Netscape) format (multiple)")
+	addSource(BookmarksJsonLinkSource("").Flag(),
+		func(value string) TextLinkSource { return BookmarksJsonLinkSource(value) },
+		"Process Chrome or Chromium Bookmarks `FILE` (multiple)")
+	addSource(MboxLinkSource("").Flag(),
+		func(value string) TextLinkSource { return MboxLinkSource(value) },
+		"Process mail messages from mailbox `FILE` in mbox format (multiple)")
+	addSource(MaildirLinkSource("").Flag(),
+		func(value string) TextLinkSource { return MaildirLinkSource(value) },
+		"Process mail messages from Maildir `DIR` (multiple)")
+	addSource(MailMessageLinkSource("").Flag(),
+		func(value string) TextLinkSource { return MailMessageLinkSource(value) },
+		"Process `FILE` as a single mail message (multiple)")
+	addSource(BibtexLinkSource("").Flag(),
+		func(value string) TextLinkSource { return BibtexLinkSource(value) },
+		"Process `FILE` as BibTeX bibliography (multiple)")
+	addSource(CslJsonLinkSource("").Flag(),
+		func(value string) TextLinkSource { return CslJsonLinkSource(value) },
+		"Process `FILE` as CSL-JSON bibliography (multiple)")
+	addSource(DirLinkSource("").Flag(),
+		func(value string) TextLinkSource { return DirLinkSource(value) },
+		"Process files in `DIR` and its subdirectories, type is chosen by suffix (multiple)."+
+			" Hidden files and ones matched by .gitignore and .burlignore are skipped")
+	addSource(FilesFromLinkSource("").Flag(),
+		func(value string) TextLinkSource { return FilesFromLinkSource(value) },
+		"Read names of files or directories from `FILE`, one per line, e.g. org-agenda-files."+
+			" The list is read again when files are checked for modification (multiple)")
+	addSource(ExecLinkSource("").Flag(),
+		func(value string) TextLinkSource { return ExecLinkSource(value) },
+		"Run shell `COMMAND` producing JSON Lines with nodes and links, see README.org"+
+			" in pkg/burl_links. It is run again when files from its output are changed (multiple)")
+	flagSet.Var(sourceOptionsSliceFlag{slice: &options.DirInclude}, "dir-include",
+		"Add `GLOB` for files in following -dir sources (default "+
+			strings.Join(DefaultDirInclude, ", ")+"), \"\" to reset")
+	flagSet.Var(sourceOptionsSliceFlag{slice: &options.DirExclude}, "dir-exclude",
+		"Add `GLOB` to skip in following -dir sources, \"\" to reset")
+	flagSet.Var(sourceOptionsBoolFlag{&options.OrgInclude}, "org-include",
+		"Read files from #+INCLUDE and #+SETUPFILE lines in following Org sources")
+	flagSet.Var(sourceOptionsSliceFlag{slice: &options.OrgLinkProperties}, "org-link-property",
+		"Add property `NAME` with links in following Org sources (default "+
+			strings.Join(DefaultOrgLinkProperties, ", ")+"), \"\" to reset")
+	flagSet.Var(sourceOptionsSliceFlag{slice: &options.OrgSkip, allowed: OrgLinkContexts}, "org-skip",
+		"Ignore links in `CONTEXT` ("+strings.Join(OrgLinkContexts, ", ")+
+			") in following Org sources, \"\" to reset")
+	flagSet.Var(sourceOptionsPathFlag{&options.OrgLinkAbbrev}, "org-link-abbrev",
+		"Read link abbreviations for following Org sources from `FILE`"+
+			" with \"KEY REPLACEMENT\" or \"#+LINK: KEY REPLACEMENT\" lines")
+	flagSet.Var(sourceOptionsBoolFlag{&options.Decrypt}, "decrypt",
+		"Decrypt .gpg files in following sources using gpg and its agent,"+
+			" links from such files are not cached")
+	flagSet.Var(sourceOptionsEncodingFlag{&options.Encoding}, "encoding",
+		"Convert following sources from `ENCODING` ("+strings.Join(TextEncodingNames(), ", ")+
+			") unless BOM or Emacs coding cookie is present, \"\" for UTF-8")
 }
 
 func AddSourceArgs(slice MixedSrcTypeSlice, args []string) MixedSrcTypeSlice {
 	for _, arg := range args {
-		var src TextLinkSource
-		if strings.HasSuffix(arg, ".org") {
-			src = OrgLinkSource(arg)
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			slice = append(slice, DirLinkSource(arg))
 		} else {
-			src = TxtLinkSource(arg)
+			slice = append(slice, SourceForFile(arg))
 		}
-		slice = append(slice, src)
 	}
 	return slice
 }
@@ -0,0 +1,74 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+// Builder of link tree for documents with nested headings,
+// nodes are created only for headings having links.
+type headingTree struct {
+	filter    Filter
+	headings  []*Heading
+	tree      TreeChildrenNode
+	treeNodes []*TreeChildrenNode
+}
+
+func (t *headingTree) init(filter Filter) {
+	t.filter = filter
+	t.headings = make([]*Heading, 0, 10)
+	t.tree = NewTreeChildrenNode(nil)
+	t.treeNodes = make([]*TreeChildrenNode, 1, cap(t.headings)+1)
+	t.treeNodes[0] = &t.tree
+}
+
+// Make h current heading, parent heading is returned.
+func (t *headingTree) push(level int, h *Heading) *Heading {
+	if level < 1 {
+		level = 1
+	}
+	h.Level = level
+	var parent *Heading
+	for i := len(t.headings) - 1; i >= 0; i-- {
+		if i < level-1 && t.headings[i] != nil {
+			parent = t.headings[i]
+			break
+		}
+	}
+	if level > cap(t.headings) {
+		level = cap(t.headings) - 1
+	}
+	t.headings = t.headings[0 : level-1]
+	if len(t.treeNodes) >= level {
+		t.treeNodes = t.treeNodes[0:level]
+	}
+	for i := len(t.headings); i < level-1; i++ {
+		t.headings = append(t.headings, nil)
+	}
+	t.headings = append(t.headings, h)
+	return parent
+}
+
+// Add link to the current heading.
+func (t *headingTree) add(link *Link) {
+	if t.filter != nil && !t.filter(link) {
+		return
+	}
+	for i := len(t.treeNodes) - 1; i < len(t.headings); i++ {
+		newNode := NewTreeChildrenNode(t.headings[i])
+		t.treeNodes = append(t.treeNodes, &newNode)
+		t.treeNodes[i].AddChild(&newNode)
+	}
+	tip := t.treeNodes[len(t.treeNodes)-1]
+	tip.AddLink(link)
+}
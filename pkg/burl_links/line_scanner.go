@@ -0,0 +1,69 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Reader of lines of arbitrary length. Unlike bufio.Scanner it does not
+// fail with bufio.ErrTooLong, so e.g. pasted minified data do not prevent
+// extraction of links from the rest of file. Line endings are stripped
+// the same way as by bufio.ScanLines.
+type lineScanner struct {
+	reader *bufio.Reader
+	line   []byte
+	err    error
+}
+
+func newLineScanner(reader io.Reader) *lineScanner {
+	return &lineScanner{reader: bufio.NewReader(reader)}
+}
+
+func (s *lineScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		s.err = err
+		if err != io.EOF || len(line) == 0 {
+			s.line = nil
+			return false
+		}
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	s.line = bytes.TrimSuffix(line, []byte("\r"))
+	return true
+}
+
+// Valid till next call of Scan.
+func (s *lineScanner) Bytes() []byte {
+	return s.line
+}
+
+func (s *lineScanner) Text() string {
+	return string(s.line)
+}
+
+func (s *lineScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/maxnikulin/burl/pkg/burl_url"
+)
+
+// Position of a link in the tree.
+type LinkLocation struct {
+	Link *Link
+	// Nodes from the root to the leaf containing the link.
+	Path []TreeBaseNode
+	// Depth-first order of links in the tree.
+	seq int
+}
+
+// Inverted index of a link tree: URL to nodes containing links to it.
+// Allows to avoid traversal of whole tree for every query.
+// Tree must not be modified after creation of the index.
+// URLs are compared using burl_url.CanonicalKey.
+type LinkIndex struct {
+	// Canonical key to locations.
+	locations map[string][]*LinkLocation
+	// Sorted unique URLs and their canonical forms for prefix queries.
+	urls []string
+}
+
+func NewLinkIndex(tree TreeBaseNode) *LinkIndex {
+	index := &LinkIndex{locations: map[string][]*LinkLocation{}}
+	if tree == nil {
+		return index
+	}
+	seq := 0
+	seen := map[string]bool{}
+	path := make([]TreeBaseNode, 0, 16)
+	queue := NewDepthFirstQueue(tree)
+	for !queue.Empty() {
+		item := queue.Pop()
+		node := item.Node.(TreeBaseNode)
+		if item.Post {
+			path = path[:len(path)-1]
+			continue
+		}
+		path = append(path, node)
+		if leaf, ok := node.(*TreeLeafNode); ok {
+			nodePath := make([]TreeBaseNode, len(path))
+			copy(nodePath, path)
+			for _, link := range leaf.Links {
+				// Canonical form allows to find e.g. "youtu.be" links
+				// in linkSet for "https://www.youtube.com/" prefix.
+				for _, url := range []string{link.URL, burl_url.Canonical(link.URL)} {
+					if !seen[url] {
+						seen[url] = true
+						index.urls = append(index.urls, url)
+					}
+				}
+				key := burl_url.CanonicalKey(link.URL)
+				index.locations[key] = append(index.locations[key], &LinkLocation{link, nodePath, seq})
+				seq++
+			}
+		}
+		children := node.GetChildrenNodes()
+		for i := len(children); i > 0; i-- {
+			queue.Push(children[i-1])
+		}
+	}
+	sort.Strings(index.urls)
+	return index
+}
+
+// Locations of links equivalent to any of urls in the order of the tree.
+func (index *LinkIndex) Lookup(urls []string) []*LinkLocation {
+	var result []*LinkLocation
+	seen := map[*Link]bool{}
+	for _, url := range urls {
+		for _, location := range index.locations[burl_url.CanonicalKey(url)] {
+			if !seen[location.Link] {
+				seen[location.Link] = true
+				result = append(result, location)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].seq < result[j].seq
+	})
+	return result
+}
+
+// The same result as CountDescendants with filter accepting urls,
+// but only branches having matched links are visited.
+func (index *LinkIndex) CountMentions(urls []string) *LimitCountNode {
+	var root *LimitCountNode
+	attrNodes := map[TreeBaseNode]*LimitCountNode{}
+	for _, location := range index.Lookup(urls) {
+		var parent *LimitCountNode
+		for _, node := range location.Path {
+			attrNode := attrNodes[node]
+			if attrNode == nil {
+				var newNode TreeBaseNode
+				if _, ok := node.(*TreeLeafNode); ok {
+					newNode = &TreeLeafNode{make([]*Link, 0, 1)}
+				} else {
+					newNode = node.(TreeClonableBaseNode).Clone(nil)
+				}
+				attrNode = &LimitCountNode{&LimitCountAttrs{}, newNode}
+				attrNodes[node] = attrNode
+				if parent != nil {
+					parent.AppendChild(attrNode)
+				} else {
+					root = attrNode
+				}
+			}
+			attrNode.Attrs.Count++
+			parent = attrNode
+		}
+		leaf := parent.Node.(*TreeLeafNode)
+		leaf.Links = append(leaf.Links, location.Link)
+	}
+	return root
+}
+
+// Add to result indexed URLs having any of prefixes. If some prefix
+// has a scheme excluded from index, e.g. not in SchemeVariants, ok is false
+// and the caller should extract links from files.
+func (index *LinkIndex) LinkSet(prefixes []string, result map[string]bool) (ok bool, err error) {
+	if _, err := MakeLinkSetBase(prefixes); err != nil {
+		return false, err
+	}
+	for _, prefix := range prefixes {
+		if !strings.Contains(prefix, ":") {
+			prefix += ":"
+		}
+		if !reScheme.MatchString(prefix) {
+			return false, nil
+		}
+	}
+	for _, prefix := range prefixes {
+		if !strings.Contains(prefix, ":") {
+			prefix += ":"
+		}
+		for i := sort.SearchStrings(index.urls, prefix); i < len(index.urls); i++ {
+			url := index.urls[i]
+			if !strings.HasPrefix(url, prefix) {
+				break
+			}
+			result[url] = true
+		}
+	}
+	return true, nil
+}
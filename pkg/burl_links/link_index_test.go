@@ -0,0 +1,201 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const linkIndexTestOrg = `Top https://top.example.com/
+* First https://a.example.com/
+** Nested
+Text with [[https://b.example.com/][b]] and https://a.example.com/ again.
+*** Deep
+<https://a.example.com/>
+* Second
+mid:message@example.com
+** Nested again https://b.example.com/
+* Third without links
+`
+
+func linkIndexTestTree(t *testing.T) *TreeChildrenNode {
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(linkIndexTestOrg), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Props = &FileProps{Path: "test.org"}
+	group := NewTreeChildrenNode(&FileGroupProps{})
+	group.AppendChild(tree)
+	return &group
+}
+
+func TestLinkIndexCountMentions(t *testing.T) {
+	tree := linkIndexTestTree(t)
+	index := NewLinkIndex(tree)
+	for _, urls := range [][]string{
+		{"https://a.example.com/"},
+		{"https://b.example.com/", "https://top.example.com/"},
+		{"mid:message@example.com", "https://b.example.com/"},
+	} {
+		t.Run(strings.Join(urls, ","), func(t *testing.T) {
+			filter := func(link *Link) bool {
+				for _, url := range urls {
+					if link.URL == url {
+						return true
+					}
+				}
+				return false
+			}
+			expected, err := json.Marshal(CountDescendants(tree, filter))
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := json.Marshal(index.CountMentions(urls))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(expected) != string(actual) {
+				t.Errorf("index result differs from tree traversal:\n%s\n%s", expected, actual)
+			}
+		})
+	}
+	if index.CountMentions([]string{"https://absent.example.com/"}) != nil {
+		t.Errorf("nil result expected for URL without mentions")
+	}
+}
+
+func TestLinkIndexLinkSet(t *testing.T) {
+	index := NewLinkIndex(linkIndexTestTree(t))
+	result := map[string]bool{}
+	ok, err := index.LinkSet([]string{"mid", "https://b."}, result)
+	if !ok || err != nil {
+		t.Fatalf("unexpected ok %v, err %v", ok, err)
+	}
+	actual := make([]string, 0, len(result))
+	for url := range result {
+		actual = append(actual, url)
+	}
+	sort.Strings(actual)
+	expected := []string{"https://b.example.com/", "mid:message@example.com"}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("%v != %v", expected, actual)
+	}
+	if ok, err := index.LinkSet([]string{"ftp:"}, result); ok || err != nil {
+		t.Errorf("not indexed scheme should be reported: ok %v, err %v", ok, err)
+	}
+}
+
+func TestLinkIndexCanonicalLookup(t *testing.T) {
+	input := "* Captured\nhttps://Example.com/a?utm_source=feed&id=1\n" +
+		"* Other\nhttp://example.com:80/a/?id=2\n"
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewLinkIndex(tree)
+	locations := index.Lookup([]string{"https://example.com/a?id=1&fbclid=xyz"})
+	if len(locations) != 1 || locations[0].Link.URL != "https://Example.com/a?utm_source=feed&id=1" {
+		t.Errorf("link with tracking parameters is not found: %+v", locations)
+	}
+	if locations := index.Lookup([]string{"https://example.com/a/?id=3"}); len(locations) != 0 {
+		t.Errorf("query should be significant: %+v", locations)
+	}
+}
+
+func TestLinkIndexLinkSetCanonical(t *testing.T) {
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader("https://youtu.be/abc123\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]bool{}
+	if ok, err := NewLinkIndex(tree).LinkSet([]string{"https://www.youtube.com/"}, result); !ok || err != nil {
+		t.Fatalf("index should be used: %v %v", ok, err)
+	}
+	if expect := map[string]bool{"https://www.youtube.com/watch?v=abc123": true}; !reflect.DeepEqual(result, expect) {
+		t.Errorf("%v != %v", result, expect)
+	}
+}
+
+func TestLinkIndexLinkSetTxt(t *testing.T) {
+	input := "See https://example.com/search?q=x#frag.\n"
+	tree, err := TxtLinkSource("test.txt").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]bool{}
+	if ok, err := NewLinkIndex(tree).LinkSet([]string{"https://example.com/"}, result); !ok || err != nil {
+		t.Fatalf("index should be used: %v %v", ok, err)
+	}
+	if !result["https://example.com/search?q=x#frag"] {
+		t.Errorf("query and fragment are lost: %v", result)
+	}
+}
+
+func TestLinkIndexIdentifiers(t *testing.T) {
+	input := "* Paper\ndoi:10.1000/XYZ\n* Book\n[[https://isbnsearch.org/isbn/0306406152][Book]]\n"
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewLinkIndex(tree)
+	for query, expect := range map[string]string{
+		"https://dx.doi.org/10.1000/xyz": "doi:10.1000/XYZ",
+		"isbn:978-0-306-40615-7":         "https://isbnsearch.org/isbn/0306406152",
+	} {
+		locations := index.Lookup([]string{query})
+		if len(locations) != 1 || locations[0].Link.URL != expect {
+			t.Errorf("%s: %s is not found: %+v", query, expect, locations)
+		}
+	}
+	result := map[string]bool{}
+	if ok, err := index.LinkSet([]string{"https://doi.org/"}, result); !ok || err != nil {
+		t.Fatalf("index should be used: %v %v", ok, err)
+	}
+	if !result["https://doi.org/10.1000/XYZ"] {
+		t.Errorf("case of DOI should be preserved for anchors: %v", result)
+	}
+}
+
+func TestLinkIndexMessageId(t *testing.T) {
+	input := "* Thread\n[[notmuch:id:87abc@Example.com][discussion]]\n"
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewLinkIndex(tree)
+	for _, query := range []string{"mid:87abc@example.com", "https://list.orgmode.org/87abc@example.com/"} {
+		if locations := index.Lookup([]string{query}); len(locations) != 1 {
+			t.Errorf("%s: message link is not found: %+v", query, locations)
+		}
+	}
+}
+
+func TestLinkIndexUnicode(t *testing.T) {
+	input := "* Wiki\n[[https://ru.wikipedia.org/wiki/Кошка][cat]]\n"
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := NewLinkIndex(tree)
+	query := "https://ru.wikipedia.org/wiki/%D0%9A%D0%BE%D1%88%D0%BA%D0%B0"
+	if locations := index.Lookup([]string{query}); len(locations) != 1 {
+		t.Errorf("%s: link is not found: %+v", query, locations)
+	}
+}
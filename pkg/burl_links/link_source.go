@@ -16,8 +16,15 @@
 package burl_links
 
 import (
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
 )
 
 type Filter func(*Link) bool
@@ -31,22 +38,59 @@ type TextLinkSource interface {
 	Clone(string) TextLinkSource
 }
 
+// Source that is not a regular file, e.g. output of a command.
+// It can not be checked for modification by stat, so it is read again
+// only when files from FileProps.Includes are changed.
+type OpenableLinkSource interface {
+	Open() (io.ReadCloser, error)
+}
+
+// Check whether source name is a path that may be stat'ed.
+func IsFileSource(src TextLinkSource) bool {
+	inner, _ := UnwrapSource(src)
+	_, ok := inner.(OpenableLinkSource)
+	return !ok
+}
+
+// Stdin for "-", otherwise a file or OpenableLinkSource.
+// Compressed and encrypted files are decoded, see CompressedSuffixes,
+// text is converted to UTF-8.
+func openSource(src TextLinkSource) (io.ReadCloser, error) {
+	inner, options := UnwrapSource(src)
+	if openable, ok := inner.(OpenableLinkSource); ok {
+		return openable.Open()
+	}
+	if src.Name() == "-" {
+		return decodeFile("", ioutil.NopCloser(os.Stdin), options)
+	}
+	file, err := os.Open(src.Name())
+	if err != nil {
+		return nil, err
+	}
+	return decodeFile(src.Name(), file, options)
+}
+
 // Separate function to have proper scope for file.Close
 func ExtractLinksFromFile(src TextLinkSource, filter Filter) (*TreeChildrenNode, error) {
-	reader := os.Stdin
-	name := src.Name()
-	if name != "-" {
-		file, err := os.Open(name)
-		if err != nil {
-			return nil, err
-		}
-		reader = file
-		defer file.Close()
+	file, err := openSource(src)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := ExtractLinksFromReader(src, file, filter)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
 	}
+	return tree, err
+}
+
+// Set file properties for the extracted tree, nil is returned for empty one
+// unless it depends on other files.
+func ExtractLinksFromReader(src TextLinkSource, reader io.Reader, filter Filter) (*TreeChildrenNode, error) {
 	tree, err := src.Extract(reader, filter)
 	if tree != nil {
-		if !tree.Empty() {
-			tree.Props = &FileProps{name}
+		includes := treeIncludes(tree)
+		if !tree.Empty() || len(includes) > 0 {
+			tree.Props = &FileProps{Path: src.Name(), Includes: includes}
 		} else {
 			tree = nil
 		}
@@ -54,50 +98,155 @@ func ExtractLinksFromFile(src TextLinkSource, filter Filter) (*TreeChildrenNode,
 	return tree, err
 }
 
+// Maximal number of files parsed simultaneously.
+var ExtractWorkers = runtime.NumCPU()
+
+// Failures of particular sources of a group. Links from other files
+// are still available.
+type SourceErrors []error
+
+func (e SourceErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Add file name unless it is already a part of err message.
+func sourceError(src TextLinkSource, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		return err
+	}
+	return fmt.Errorf("%s: %w", src.Name(), err)
+}
+
+// Call fn for indices from 0 to count-1 using at most ExtractWorkers goroutines.
+func parallelFor(count int, fn func(i int)) {
+	workers := ExtractWorkers
+	if workers > count {
+		workers = count
+	}
+	if workers < 2 {
+		for i := 0; i < count; i++ {
+			fn(i)
+		}
+		return
+	}
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < count; i++ {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+}
+
+// Files are parsed concurrently, order of file nodes is the same as in list.
+// Failed files are skipped, their errors are reported as SourceErrors.
 func ExtractLinksFromFileGroup(list []TextLinkSource, filter Filter) (*TreeChildrenNode, error) {
 	if len(list) == 0 {
 		return nil, nil // TODO error
 	}
-	var err error
-	var tree *TreeChildrenNode
-	g := NewTreeChildrenNode(&FileGroupProps{})
+	list, expandErr := ExpandSources(list)
+	trees := make([]*TreeChildrenNode, len(list))
+	errs := make([]error, len(list))
+	parallelFor(len(list), func(i int) {
+		trees[i], errs[i] = ExtractLinksFromFile(list[i], filter)
+	})
+	props := &FileGroupProps{}
+	g := NewTreeChildrenNode(props)
 	group := &g
-	for _, src := range list {
-		tree, err = ExtractLinksFromFile(src, filter)
+	var failures SourceErrors
+	if expandErr != nil {
+		failures = expandErr.(SourceErrors)
+		for _, err := range failures {
+			props.Errors = append(props.Errors, err.Error())
+		}
+	}
+	for i, tree := range trees {
 		if tree != nil {
 			group.AppendChild(tree)
 		}
-		if err != nil {
-			break
+		if errs[i] != nil {
+			err := sourceError(list[i], errs[i])
+			failures = append(failures, err)
+			props.Errors = append(props.Errors, err.Error())
 		}
 	}
 	if group.Empty() {
 		group = nil
 	}
-	return group, err
+	if failures != nil {
+		return group, failures
+	}
+	return group, nil
 }
 
+// URLs matching prefixes from all files. Failed files are skipped,
+// their errors are reported as SourceErrors, see ExtractLinksFromFileGroup.
 func ExtractLinkSetFromFileGroup(list []TextLinkSource, filters []string) (map[string]bool, error) {
 	result := map[string]bool{}
-	var err error
+	if _, err := MakeLinkSetBase(filters); err != nil {
+		return result, err
+	}
+	list, expandErr := ExpandSources(list)
+	var failures SourceErrors
+	if expandErr != nil {
+		failures = expandErr.(SourceErrors)
+	}
 	for _, src := range list {
-		var reader io.Reader
-		name := src.Name()
-		if name == "-" {
-			reader = os.Stdin
-		} else {
-			var file io.ReadCloser
-			file, err = os.Open(name)
-			if err != nil {
-				break
-			}
-			reader = file
-			defer file.Close()
+		file, err := openSource(src)
+		if err != nil {
+			failures = append(failures, sourceError(src, err))
+			continue
+		}
+		err = src.ExtractSet(file, filters, &result)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
 		}
-		err = src.ExtractSet(reader, filters, &result)
 		if err != nil {
-			break
+			failures = append(failures, sourceError(src, err))
 		}
 	}
-	return result, err
+	if failures != nil {
+		return result, failures
+	}
+	return result, nil
+}
+
+// ExtractSet implementation for sources that have no dedicated regexp.
+func extractSetFromTree(
+	extract func(io.Reader, Filter) (*TreeChildrenNode, error),
+	file io.Reader, filters []string, result *map[string]bool,
+) error {
+	base, err := MakeLinkSetBase(filters)
+	if err != nil {
+		return err
+	}
+	rePrefix, err := regexp.Compile("^" + base)
+	if err != nil {
+		return err
+	}
+	tree, err := extract(file, func(link *Link) bool {
+		return rePrefix.MatchString(link.URL)
+	})
+	if err != nil {
+		return err
+	}
+	ForEachLink(tree, func(link *Link) bool {
+		(*result)[link.URL] = true
+		return true
+	})
+	return nil
 }
@@ -0,0 +1,97 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractLinksFromFileGroupPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func(workers int) { ExtractWorkers = workers }(ExtractWorkers)
+	ExtractWorkers = 3
+
+	var list []TextLinkSource
+	var expected []string
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("file%d.org", i))
+		list = append(list, OrgLinkSource(name))
+		if i%4 == 1 {
+			continue // missed file
+		}
+		content := fmt.Sprintf("* Heading\nhttps://example.com/%d\n", i)
+		if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		expected = append(expected, name)
+	}
+
+	group, err := ExtractLinksFromFileGroup(list, nil)
+	var failures SourceErrors
+	if !errors.As(err, &failures) || len(failures) != 3 {
+		t.Errorf("expected 3 SourceErrors, got %v", err)
+	}
+	if group == nil {
+		t.Fatalf("partial result expected")
+	}
+	if len(group.Children) != len(expected) {
+		t.Fatalf("expected %d files, got %d", len(expected), len(group.Children))
+	}
+	for i, child := range group.Children {
+		props := child.(*TreeChildrenNode).Props.(*FileProps)
+		if props.Path != expected[i] {
+			t.Errorf("file order is not preserved: %d %s != %s", i, props.Path, expected[i])
+		}
+	}
+	if errors := group.Props.(*FileGroupProps).Errors; len(errors) != 3 {
+		t.Errorf("errors should be attached to the group: %v", errors)
+	}
+}
+
+func TestExtractLinkSetFromFileGroupPartial(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var list []TextLinkSource
+	for _, name := range []string{"missed.org", "journal.org.gpg", "notes.org"} {
+		list = append(list, SourceForFile(filepath.Join(dir, name)))
+	}
+	for _, name := range []string{"journal.org.gpg", "notes.org"} {
+		content := []byte("https://example.com/" + name + "\n")
+		if err := ioutil.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := ExtractLinkSetFromFileGroup(list, []string{"https://example.com/"})
+	var failures SourceErrors
+	if !errors.As(err, &failures) || len(failures) != 2 {
+		t.Errorf("expected 2 SourceErrors, got %v", err)
+	}
+	if len(result) != 1 || !result["https://example.com/notes.org"] {
+		t.Errorf("links from readable file expected: %v", result)
+	}
+}
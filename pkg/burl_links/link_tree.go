@@ -52,6 +52,16 @@ type Link struct {
 	URL         string `json:"url"`
 	Description string `json:"descr,omitempty"`
 	LineNo      int    `json:"lineNo"`
+	// Set if link is in another file than its parent node,
+	// e.g. included into an Org file.
+	File string `json:"file,omitempty"`
+	// Name of Org property, front matter key, or mail header if the link
+	// is its value. URL-like properties and Message-ID describe the heading
+	// or the message rather than just mentioned in it.
+	Property string `json:"property,omitempty"`
+	// Where the link is found if it is not a part of regular text,
+	// e.g. "src" block, see OrgLinkContexts.
+	Context string `json:"context,omitempty"`
 }
 
 func (l *Link) MarshalJSON() ([]byte, error) {
@@ -129,6 +139,8 @@ func (t *TreeLeafNode) LimitChildrenCount(target int) int {
 
 type FileProps struct {
 	Path string `json:"path"`
+	// Files that affect extracted links, e.g. Org #+INCLUDE.
+	Includes []FileStamp `json:"-"`
 }
 
 var _ TreeNodeProps = (*FileProps)(nil)
@@ -137,7 +149,10 @@ func (_ *FileProps) BurlType() string {
 	return "File"
 }
 
-type FileGroupProps struct{}
+type FileGroupProps struct {
+	// Failures of particular files, links from other ones are available.
+	Errors []string `json:"errors,omitempty"`
+}
 
 var _ TreeNodeProps = (*FileGroupProps)(nil)
 
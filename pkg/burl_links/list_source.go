@@ -0,0 +1,128 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errListNotExpanded = errors.New("file list source must be expanded to files")
+
+// File with names of note files, one per line, like the one that may be
+// specified as org-agenda-files in Emacs. Lines starting with "#"
+// are comments. "~/" is expanded to home directory, relative paths
+// are resolved against directory of the list file. For a directory,
+// its Org files (possibly compressed) are used (not recursively, the same as in Emacs).
+// The list is read again every time when files are checked for modification.
+type FilesFromLinkSource string
+
+var _ TextLinkSource = (*FilesFromLinkSource)(nil)
+var _ ExpandableLinkSource = (*FilesFromLinkSource)(nil)
+
+func (s FilesFromLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ FilesFromLinkSource) Flag() string {
+	return "files-from"
+}
+
+func (_ FilesFromLinkSource) Clone(src string) TextLinkSource {
+	v := FilesFromLinkSource(src)
+	return &v
+}
+
+func (_ FilesFromLinkSource) Extract(_ io.Reader, _ Filter) (*TreeChildrenNode, error) {
+	return nil, errListNotExpanded
+}
+
+func (_ FilesFromLinkSource) ExtractSet(_ io.Reader, _ []string, _ *map[string]bool) error {
+	return errListNotExpanded
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path, err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+func (s FilesFromLinkSource) Expand(options *SourceOptions) ([]TextLinkSource, error) {
+	file, err := os.Open(string(s))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadFileList(file, filepath.Dir(string(s)), options)
+}
+
+// Parse list of files, see FilesFromLinkSource. Encrypted files
+// in directories are skipped unless options.Decrypt is set.
+func ReadFileList(reader io.Reader, baseDir string, options *SourceOptions) ([]TextLinkSource, error) {
+	var retval []TextLinkSource
+	var failures SourceErrors
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		path, err := expandHome(line)
+		if err != nil {
+			failures = append(failures, err)
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			entries, err := ioutil.ReadDir(path)
+			if err != nil {
+				failures = append(failures, err)
+				continue
+			}
+			for _, entry := range entries {
+				name := entry.Name()
+				if !entry.IsDir() && !strings.HasPrefix(name, ".") &&
+					strings.HasSuffix(StripCompressedSuffix(name), ".org") &&
+					(options.Decrypt || !isEncrypted(name)) {
+					retval = append(retval, SourceForFile(filepath.Join(path, name)))
+				}
+			}
+			continue
+		}
+		// Missed files are reported later when they are read.
+		retval = append(retval, SourceForFile(path))
+	}
+	if err := scanner.Err(); err != nil {
+		failures = append(failures, err)
+	}
+	if failures != nil {
+		return retval, failures
+	}
+	return retval, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFilesFromLinkSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", filepath.Join(dir, "home"))
+	for _, name := range []string{"agenda/a.org", "agenda/b.txt", "agenda/.#lock.org", "agenda/sub/c.org", "agenda/j.org.gpg"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	listPath := filepath.Join(dir, "agenda-files")
+	list := `# Comment
+~/org/inbox.org
+  relative.txt
+
+agenda
+` + filepath.Join(dir, "absolute.org") + "\n"
+	if err := ioutil.WriteFile(listPath, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := ExpandSources([]TextLinkSource{FilesFromLinkSource(listPath)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	for _, src := range sources {
+		actual = append(actual, src.Flag()+":"+src.Name())
+	}
+	expected := []string{
+		"org:" + filepath.Join(dir, "home", "org", "inbox.org"),
+		"txt:" + filepath.Join(dir, "relative.txt"),
+		"org:" + filepath.Join(dir, "agenda", "a.org"),
+		"org:" + filepath.Join(dir, "absolute.org"),
+	}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("%v != %v", expected, actual)
+	}
+
+	decrypted := WithOptions(FilesFromLinkSource(listPath), &SourceOptions{Decrypt: true})
+	sources, err = ExpandSources([]TextLinkSource{decrypted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != len(expected)+1 || sources[len(expected)-1].Name() != filepath.Join(dir, "agenda", "j.org.gpg") {
+		t.Errorf("encrypted file should be added when decryption is enabled: %v", sources)
+	}
+}
@@ -0,0 +1,333 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var reMessageId = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// Headers with message identifiers. Message-ID of the message itself
+// describes the node, others refer to other messages in the thread.
+var mailIdHeaders = []string{"Message-ID", "In-Reply-To", "References"}
+
+// Mail message, links are reported as "mid:" URLs for Message-ID,
+// In-Reply-To, and References headers and found in text/plain parts.
+type MailMessage struct {
+	Subject   string `json:"subject"`
+	From      string `json:"from,omitempty"`
+	Date      string `json:"date,omitempty"`
+	MessageId string `json:"messageId,omitempty"`
+	LineNo    int    `json:"lineNo"`
+}
+
+var _ TreeNodeProps = (*MailMessage)(nil)
+
+func (_ *MailMessage) BurlType() string {
+	return "MailMessage"
+}
+
+// Mailbox file in mbox format, e.g. local folder of Thunderbird.
+type MboxLinkSource string
+
+var _ TextLinkSource = (*MboxLinkSource)(nil)
+
+func (s MboxLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ MboxLinkSource) Flag() string {
+	return "mbox"
+}
+
+func (_ MboxLinkSource) Clone(src string) TextLinkSource {
+	v := MboxLinkSource(src)
+	return &v
+}
+
+func (_ MboxLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	tree := NewTreeChildrenNode(nil)
+	scanner := newLineScanner(file)
+	var message []string
+	start, lineNo := 1, 0
+	previousEmpty := true
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		if previousEmpty && strings.HasPrefix(line, "From ") {
+			addMailMessage(&tree, message, start, filter)
+			message = message[:0]
+			start = lineNo + 1
+		} else {
+			message = append(message, line)
+		}
+		previousEmpty = line == ""
+	}
+	addMailMessage(&tree, message, start, filter)
+	return &tree, scanner.Err()
+}
+
+func (s MboxLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
+
+// Single message in a file, e.g. ".eml" or a file in Maildir.
+type MailMessageLinkSource string
+
+var _ TextLinkSource = (*MailMessageLinkSource)(nil)
+
+func (s MailMessageLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ MailMessageLinkSource) Flag() string {
+	return "eml"
+}
+
+func (_ MailMessageLinkSource) Clone(src string) TextLinkSource {
+	v := MailMessageLinkSource(src)
+	return &v
+}
+
+func (_ MailMessageLinkSource) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	tree := NewTreeChildrenNode(nil)
+	var message []string
+	scanner := newLineScanner(file)
+	for scanner.Scan() {
+		message = append(message, scanner.Text())
+	}
+	addMailMessage(&tree, message, 1, filter)
+	return &tree, scanner.Err()
+}
+
+func (s MailMessageLinkSource) ExtractSet(file io.Reader, filters []string, result *map[string]bool) error {
+	return extractSetFromTree(s.Extract, file, filters, result)
+}
+
+// Maildir directory, messages from its "cur" and "new" subdirectories
+// are read every time when files are checked for modification.
+type MaildirLinkSource string
+
+var _ TextLinkSource = (*MaildirLinkSource)(nil)
+var _ ExpandableLinkSource = (*MaildirLinkSource)(nil)
+
+var errMaildirNotExpanded = errors.New("maildir source must be expanded to files")
+
+func (s MaildirLinkSource) Name() string {
+	return string(s)
+}
+
+func (_ MaildirLinkSource) Flag() string {
+	return "maildir"
+}
+
+func (_ MaildirLinkSource) Clone(src string) TextLinkSource {
+	v := MaildirLinkSource(src)
+	return &v
+}
+
+func (_ MaildirLinkSource) Extract(_ io.Reader, _ Filter) (*TreeChildrenNode, error) {
+	return nil, errMaildirNotExpanded
+}
+
+func (_ MaildirLinkSource) ExtractSet(_ io.Reader, _ []string, _ *map[string]bool) error {
+	return errMaildirNotExpanded
+}
+
+func (s MaildirLinkSource) Expand(_ *SourceOptions) ([]TextLinkSource, error) {
+	var retval []TextLinkSource
+	found := false
+	for _, sub := range []string{"cur", "new"} {
+		dir := filepath.Join(string(s), sub)
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return retval, err
+		}
+		found = true
+		names := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			retval = append(retval, MailMessageLinkSource(filepath.Join(dir, name)))
+		}
+	}
+	if !found {
+		return nil, errors.New("not a Maildir, no cur or new subdirectory")
+	}
+	return retval, nil
+}
+
+// Parse message and add its node to tree if it has links.
+// Line numbers are precise for not encoded text/plain bodies,
+// for other parts the first line of body is used.
+func addMailMessage(tree *TreeChildrenNode, lines []string, start int, filter Filter) {
+	headerEnd := len(lines)
+	for i, line := range lines {
+		if line == "" {
+			headerEnd = i
+			break
+		}
+	}
+	if headerEnd == 0 {
+		return
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(strings.Join(lines[:headerEnd], "\r\n") + "\r\n\r\n"))
+	if err != nil {
+		return
+	}
+	header := msg.Header
+	decoder := new(mime.WordDecoder)
+	decode := func(name string) string {
+		value := header.Get(name)
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+	props := &MailMessage{
+		Subject: decode("Subject"),
+		From:    decode("From"),
+		Date:    header.Get("Date"),
+		LineNo:  start,
+	}
+	if match := reMessageId.FindStringSubmatch(header.Get("Message-ID")); match != nil {
+		props.MessageId = match[1]
+	}
+	node := NewTreeChildrenNode(props)
+	add := func(link *Link) {
+		if filter == nil || filter(link) {
+			node.AddLink(link)
+		}
+	}
+	for _, name := range mailIdHeaders {
+		for _, value := range header[textproto.CanonicalMIMEHeaderKey(name)] {
+			for _, match := range reMessageId.FindAllStringSubmatch(value, -1) {
+				add(&Link{URL: "mid:" + match[1], LineNo: start, Property: name})
+			}
+		}
+	}
+	bodyStart := start + headerEnd + 1
+	var body []string
+	if headerEnd < len(lines) {
+		body = lines[headerEnd+1:]
+	}
+	mailBodyLinks(mailPartHeader(header), strings.Join(body, "\n"), func(text string, precise bool) {
+		for i, line := range strings.Split(text, "\n") {
+			lineNo := bodyStart
+			if precise {
+				lineNo += i
+			}
+			for _, url := range findTextUrls(line) {
+				add(&Link{URL: url, LineNo: lineNo})
+			}
+		}
+	})
+	if !node.Empty() {
+		tree.AddChild(&node)
+	}
+}
+
+type mailPartHeader interface {
+	Get(key string) string
+}
+
+// Call fn for text/plain parts, precise is false if text is decoded
+// so its lines do not correspond to lines of the file.
+func mailBodyLinks(header mailPartHeader, body string, fn func(text string, precise bool)) {
+	contentType := header.Get("Content-Type")
+	mediaType, params := "text/plain", map[string]string(nil)
+	if contentType != "" {
+		var err error
+		if mediaType, params, err = mime.ParseMediaType(contentType); err != nil {
+			return
+		}
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mailMultipartLinks(body, params["boundary"], fn)
+		return
+	}
+	if mediaType != "text/plain" {
+		return
+	}
+	charset := params["charset"]
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		content, err := ioutil.ReadAll(quotedprintable.NewReader(strings.NewReader(body)))
+		if err == nil {
+			fn(mailText(content, charset), false)
+		}
+	case "base64":
+		content, err := ioutil.ReadAll(base64.NewDecoder(base64.StdEncoding,
+			strings.NewReader(strings.Join(strings.Fields(body), ""))))
+		if err == nil {
+			fn(mailText(content, charset), false)
+		}
+	default:
+		// 8bit text is passed by the file reader as is.
+		fn(mailText([]byte(body), charset), true)
+	}
+}
+
+// Convert text/plain content to UTF-8 according to its charset,
+// unsupported ones are treated as UTF-8.
+func mailText(content []byte, charset string) string {
+	if _, err := lookupTextEncoding(charset); err != nil {
+		charset = ""
+	}
+	if decoded, err := decodeText(content, charset); err == nil {
+		return string(decoded)
+	}
+	return string(content)
+}
+
+func mailMultipartLinks(body string, boundary string, fn func(text string, precise bool)) {
+	reader := multipart.NewReader(strings.NewReader(body), boundary)
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return
+		}
+		// quoted-printable is decoded by multipart.Reader
+		content, err := ioutil.ReadAll(part)
+		if err != nil {
+			return
+		}
+		mailBodyLinks(part.Header, string(content), func(text string, _ bool) {
+			fn(text, false)
+		})
+	}
+}
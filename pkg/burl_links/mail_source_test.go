@@ -0,0 +1,172 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMbox = `From alice@example.com Mon May  2 10:00:00 2022
+Message-ID: <first@example.com>
+From: Alice <alice@example.com>
+Subject: =?UTF-8?Q?Caf=C3=A9?= links
+Date: Mon, 2 May 2022 10:00:00 +0000
+
+See https://example.com/plain and
+<https://example.com/angle>.
+
+From bob@example.com Mon May  2 11:00:00 2022
+Message-ID: <second@example.com>
+In-Reply-To: <first@example.com>
+References: <zero@example.com>
+ <first@example.com>
+Subject: Re: links
+MIME-Version: 1.0
+Content-Type: multipart/alternative; boundary="b1"
+
+--b1
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Quoted-printable https://example.com/very-long-=
+url
+--b1
+Content-Type: text/html
+
+<a href="https://example.com/html">skipped</a>
+--b1--
+`
+
+func TestMboxExtract(t *testing.T) {
+	tree, err := MboxLinkSource("test.mbox").Extract(strings.NewReader(testMbox), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tree.Children) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(tree.Children))
+	}
+	first := tree.Children[0].(*TreeChildrenNode)
+	props := first.Props.(*MailMessage)
+	if props.Subject != "Café links" || props.MessageId != "first@example.com" || props.LineNo != 2 {
+		t.Errorf("unexpected message properties %+v", props)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "mid:first@example.com", LineNo: 2, Property: "Message-ID"},
+		{URL: "https://example.com/plain", LineNo: 7},
+		{URL: "https://example.com/angle", LineNo: 8},
+		{URL: "mid:second@example.com", LineNo: 11, Property: "Message-ID"},
+		{URL: "mid:first@example.com", LineNo: 11, Property: "In-Reply-To"},
+		{URL: "mid:zero@example.com", LineNo: 11, Property: "References"},
+		{URL: "mid:first@example.com", LineNo: 11, Property: "References"},
+		{URL: "https://example.com/very-long-url", LineNo: 19},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestMailCharset(t *testing.T) {
+	input := "Message-ID: <charset@example.com>\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: multipart/mixed; boundary=b\n\n" +
+		"--b\n" +
+		"Content-Type: text/plain; charset=windows-1251\n" +
+		"Content-Transfer-Encoding: quoted-printable\n\n" +
+		"https://=EF=F0=E8=EC=E5=F0.=F0=F4/\n" +
+		"--b\n" +
+		"Content-Type: text/plain; charset=ISO-8859-1\n" +
+		"Content-Transfer-Encoding: 8bit\n\n" +
+		"https://example.com/caf\xe9\n" +
+		"--b--\n"
+	tree, err := MailMessageLinkSource("test.eml").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []string
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, link.URL)
+		return true
+	})
+	expect := []string{"mid:charset@example.com", "https://пример.рф/", "https://example.com/café"}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%q != %q", actual, expect)
+	}
+}
+
+func TestMailPlainTextUrls(t *testing.T) {
+	input := "Subject: plain\n\n" +
+		"(see https://en.wikipedia.org/wiki/Go_(language)).\n" +
+		"> quoted: «https://example.com/quote», https://example.com/s?q=x#frag;\n"
+	tree, err := MailMessageLinkSource("test.eml").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "https://en.wikipedia.org/wiki/Go_(language)", LineNo: 3},
+		{URL: "https://example.com/quote", LineNo: 4},
+		{URL: "https://example.com/s?q=x#frag", LineNo: 4},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestMaildirExpand(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if _, err := MaildirLinkSource(dir).Expand(&DefaultSourceOptions); err == nil {
+		t.Errorf("directory without cur and new should be rejected")
+	}
+	for _, sub := range []string{"cur", "new", "tmp"} {
+		if err := os.Mkdir(filepath.Join(dir, sub), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"cur/2:2,S", "new/1", "tmp/3", "cur/.hidden"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("Subject: x\n\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := MaildirLinkSource(dir).Expand(&DefaultSourceOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := []TextLinkSource{
+		MailMessageLinkSource(filepath.Join(dir, "cur", "2:2,S")),
+		MailMessageLinkSource(filepath.Join(dir, "new", "1")),
+	}
+	if !reflect.DeepEqual(files, expect) {
+		t.Errorf("%v != %v", files, expect)
+	}
+}
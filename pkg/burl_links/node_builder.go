@@ -0,0 +1,54 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+// Intermediate representation of a tree for sources where links
+// may follow child nodes but TreeLeafNode must be the first child.
+type nodeBuilder struct {
+	props    TreeNodeProps
+	links    []*Link
+	children []*nodeBuilder
+}
+
+func (b *nodeBuilder) addLink(link *Link, filter Filter) {
+	if filter != nil && !filter(link) {
+		return
+	}
+	b.links = append(b.links, link)
+}
+
+func (b *nodeBuilder) addFolder(props TreeNodeProps) *nodeBuilder {
+	child := &nodeBuilder{props: props}
+	b.children = append(b.children, child)
+	return child
+}
+
+// Nil is returned for nodes without links besides the root one.
+func (b *nodeBuilder) build() *TreeChildrenNode {
+	node := NewTreeChildrenNode(b.props)
+	if len(b.links) > 0 {
+		node.AddChild(&TreeLeafNode{b.links})
+	}
+	for _, child := range b.children {
+		if childNode := child.build(); childNode != nil {
+			node.AddChild(childNode)
+		}
+	}
+	if node.Empty() && b.props != nil {
+		return nil
+	}
+	return &node
+}
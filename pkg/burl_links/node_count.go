@@ -76,7 +76,7 @@ func reflectFlatten(m map[string]interface{}, p interface{}) {
 
 func (p *LimitCountNode) MarshalJSON() ([]byte, error) {
 	if node, ok := p.Node.(*TreeChildrenNode); ok {
-		if _, ok := node.Props.(*FileGroupProps); ok {
+		if props, ok := node.Props.(*FileGroupProps); ok && len(props.Errors) == 0 {
 			if children := node.GetChildrenNodes(); len(children) == 1 {
 				return json.Marshal(children[0])
 			}
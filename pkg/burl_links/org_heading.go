@@ -0,0 +1,192 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"regexp"
+	"strings"
+)
+
+var reOrgPriority = regexp.MustCompile(`^\[#([A-Z0-9]|[0-9]+)\][ \t]*`)
+var reOrgTags = regexp.MustCompile(`(?:^|[ \t]+)(:(?:[\pL\pN_@#%]+:)+)[ \t]*$`)
+var reOrgStatistics = regexp.MustCompile(`[ \t]*\[[0-9]*(?:%|/[0-9]*)\]`)
+var reOrgPlanning = regexp.MustCompile(`^[ \t]*(?:SCHEDULED|DEADLINE|CLOSED):`)
+var reOrgPropertiesBegin = regexp.MustCompile(`(?i)^[ \t]*:PROPERTIES:[ \t]*$`)
+var reOrgDrawerEnd = regexp.MustCompile(`(?i)^[ \t]*:END:[ \t]*$`)
+var reOrgBlockBegin = regexp.MustCompile(`(?i)^[ \t]*#\+begin_(\S+)`)
+var reOrgBlockEnd = regexp.MustCompile(`(?i)^[ \t]*#\+end_(\S+)`)
+var reOrgDrawerBegin = regexp.MustCompile(`^[ \t]*:([\w-]+):[ \t]*$`)
+var reOrgFixedWidth = regexp.MustCompile(`^[ \t]*:(?:[ \t]|$)`)
+var reOrgCommentLine = regexp.MustCompile(`^[ \t]*#(?:[ \t]|$)`)
+var reOrgComment = regexp.MustCompile(`^[ \t]*(?:#(?:[ \t].*)?)?$`)
+var reOrgProperty = regexp.MustCompile(`^[ \t]*:([^: \t]+):(?:[ \t]+(.*?))?[ \t]*$`)
+
+// Properties of Org headings that are considered as links even without
+// link markup. ROAM_REFS may contain several space-separated values.
+var DefaultOrgLinkProperties = []string{"URL", "ROAM_REFS", "SOURCE"}
+
+// Values of Link.Context for Org files.
+const (
+	// Source code block.
+	OrgContextSrc = "src"
+	// Example block or fixed-width lines.
+	OrgContextExample = "example"
+	// Export block, e.g. raw HTML.
+	OrgContextExport = "export"
+	// Comment lines or comment block.
+	OrgContextComment = "comment"
+	// Drawers besides property ones, e.g. LOGBOOK.
+	OrgContextDrawer = "drawer"
+	// Subtree of a heading with COMMENT keyword.
+	OrgContextCommented = "commented"
+	// Subtree of a heading with ARCHIVE tag.
+	OrgContextArchive = "archive"
+)
+
+var OrgLinkContexts = []string{
+	OrgContextSrc, OrgContextExample, OrgContextExport, OrgContextComment,
+	OrgContextDrawer, OrgContextCommented, OrgContextArchive,
+}
+
+// Context of links inside "#+begin_NAME" block, empty for regular text.
+func orgBlockContext(name string) string {
+	switch strings.ToLower(name) {
+	case "src":
+		return OrgContextSrc
+	case "example":
+		return OrgContextExample
+	case "export":
+		return OrgContextExport
+	case "comment":
+		return OrgContextComment
+	}
+	return ""
+}
+
+// The same as default value of org-todo-keywords in Emacs.
+var DefaultOrgTodoKeywords = []string{"TODO", "|", "DONE"}
+
+// Sets of TODO keywords specified by #+TODO, #+SEQ_TODO, or #+TYP_TODO lines.
+type orgTodoKeywords struct {
+	todo map[string]bool
+	done map[string]bool
+}
+
+// Add keywords from a line like "TODO(t) WAIT(w@/!) | DONE(d) CANCELED(c)".
+// Without "|", the last keyword means done state.
+func (k *orgTodoKeywords) add(value string) {
+	if k.todo == nil {
+		k.todo = map[string]bool{}
+		k.done = map[string]bool{}
+	}
+	words := strings.Fields(value)
+	separator := -1
+	for i, word := range words {
+		if word == "|" {
+			separator = i
+			break
+		}
+	}
+	if separator < 0 && len(words) > 0 {
+		separator = len(words) - 1
+		words = append(words[:separator:separator], "|", words[separator])
+	}
+	for i, word := range words {
+		if i == separator {
+			continue
+		}
+		if paren := strings.IndexByte(word, '('); paren > 0 && strings.HasSuffix(word, ")") {
+			// Fast access key and logging settings
+			word = word[:paren]
+		}
+		if i < separator {
+			k.todo[word] = true
+		} else {
+			k.done[word] = true
+		}
+	}
+}
+
+func (k *orgTodoKeywords) empty() bool {
+	return len(k.todo) == 0 && len(k.done) == 0
+}
+
+// Split value of #+FILETAGS, both ":a:b:" and "a b" forms are accepted.
+func parseOrgTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ':' || r == ' ' || r == '\t'
+	})
+}
+
+// Fill structured fields of heading from RawText.
+// Statistics cookies like "[1/3]" or "[50%]" are removed from title.
+func (h *Heading) parse(keywords *orgTodoKeywords) {
+	text := h.RawText
+	word := text
+	if i := strings.IndexAny(text, " \t"); i >= 0 {
+		word = text[:i]
+	}
+	if keywords.todo[word] || keywords.done[word] {
+		h.Todo = word
+		h.Done = keywords.done[word]
+		text = strings.TrimLeft(text[len(word):], " \t")
+	}
+	if match := reOrgPriority.FindStringSubmatchIndex(text); match != nil {
+		h.Priority = text[match[2]:match[3]]
+		text = text[match[1]:]
+	}
+	if text == "COMMENT" || strings.HasPrefix(text, "COMMENT ") || strings.HasPrefix(text, "COMMENT\t") {
+		h.Commented = true
+		text = text[len("COMMENT"):]
+	}
+	if match := reOrgTags.FindStringSubmatchIndex(text); match != nil {
+		h.Tags = parseOrgTags(text[match[2]:match[3]])
+		text = text[:match[0]]
+	}
+	text = reOrgStatistics.ReplaceAllString(text, "")
+	h.Title = strings.TrimSpace(text)
+}
+
+// Tags from parent heading or from #+FILETAGS that are not set for heading itself.
+func (h *Heading) inheritTags(parentTags []string) {
+	own := map[string]bool{}
+	for _, tag := range h.Tags {
+		own[tag] = true
+	}
+	for _, tag := range parentTags {
+		if !own[tag] {
+			own[tag] = true
+			h.InheritedTags = append(h.InheritedTags, tag)
+		}
+	}
+}
+
+func (h *Heading) hasTag(tag string) bool {
+	for _, t := range h.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// All tags of heading, inherited ones first.
+func (h *Heading) allTags() []string {
+	if len(h.InheritedTags) == 0 {
+		return h.Tags
+	}
+	return append(h.InheritedTags[:len(h.InheritedTags):len(h.InheritedTags)], h.Tags...)
+}
@@ -0,0 +1,103 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var reOrgLinkKeyword = regexp.MustCompile(`(?im)^[ \t]*#\+LINK:[ \t]*(.*?)[ \t]*$`)
+
+// Link abbreviations defined by #+LINK lines, the same as
+// org-link-abbrev-alist-local in Emacs. Keys are in lower case
+// since Org ignores case of abbreviations.
+type orgLinkAbbrevs map[string]string
+
+// Add "KEY REPLACEMENT" definition, the later one wins.
+func (a orgLinkAbbrevs) add(value string) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return
+	}
+	a[strings.ToLower(fields[0])] = strings.Join(fields[1:], " ")
+}
+
+// Add definitions from all #+LINK lines of the file.
+func (a orgLinkAbbrevs) scan(content []byte) {
+	for _, match := range reOrgLinkKeyword.FindAllSubmatch(content, -1) {
+		a.add(string(match[1]))
+	}
+}
+
+// Read file with "KEY REPLACEMENT" lines similar to org-link-abbrev-alist.
+// "#+LINK:" prefix is allowed to share file with #+SETUPFILE,
+// other lines starting with "#" are comments.
+func (a orgLinkAbbrevs) read(content []byte) error {
+	scanner := newLineScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if match := reOrgLinkKeyword.FindStringSubmatch(line); match != nil {
+			a.add(match[1])
+		} else if line != "" && !strings.HasPrefix(line, "#") {
+			a.add(line)
+		}
+	}
+	return scanner.Err()
+}
+
+// Expand "KEY:TAG" the same way as org-link-expand-abbrev:
+// "%s" in replacement is substituted by TAG, "%h" by encoded TAG,
+// otherwise TAG is appended. Functions, "%(...)", are not supported.
+func (a orgLinkAbbrevs) expand(link string) (string, bool) {
+	key, tag := link, ""
+	if i := strings.IndexByte(link, ':'); i >= 0 {
+		key, tag = link[:i], strings.TrimPrefix(link[i+1:], ":")
+	}
+	if strings.ContainsAny(key, "[]") {
+		return "", false
+	}
+	replacement, ok := a[strings.ToLower(key)]
+	if !ok {
+		return "", false
+	}
+	switch {
+	case strings.Contains(replacement, "%("):
+		return "", false
+	case strings.Contains(replacement, "%s"):
+		return strings.Replace(replacement, "%s", tag, 1), true
+	case strings.Contains(replacement, "%h"):
+		return strings.Replace(replacement, "%h", hexifyOrgTag(tag), 1), true
+	}
+	return replacement + tag, true
+}
+
+// The same as url-hexify-string in Emacs.
+func hexifyOrgTag(tag string) string {
+	var buffer strings.Builder
+	for _, b := range []byte(tag) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~':
+			buffer.WriteByte(b)
+		default:
+			fmt.Fprintf(&buffer, "%%%02X", b)
+		}
+	}
+	return buffer.String()
+}
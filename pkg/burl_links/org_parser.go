@@ -0,0 +1,571 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var reOrgKeyword = regexp.MustCompile(`^[ \t]*#\+([^: \t]+):[ \t]*(.*?)[ \t]*$`)
+var reIncludeMinLevel = regexp.MustCompile(`(?i):minlevel[ \t]+([0-9]+)`)
+var reIncludeLines = regexp.MustCompile(`(?i):lines[ \t]+"([0-9]*)-([0-9]*)"`)
+
+// Protection against unreasonably deep nesting of #+INCLUDE.
+var orgIncludeDepthLimit = 16
+
+// State of Org file parser shared with included files.
+type orgParser struct {
+	headingTree
+	options  *SourceOptions
+	mainFile string
+	// Files currently processed to detect include cycles.
+	stack []string
+	// Files that affect result besides mainFile.
+	includes []FileStamp
+	// Headings in document order, fields are filled by finish
+	// when all in-buffer settings are known.
+	parsed          []orgParsedHeading
+	todoKeywords    orgTodoKeywords
+	fileTags        []string
+	defaultKeywords *orgTodoKeywords
+	// Upper case names of properties with links.
+	linkProperties map[string]bool
+	// Contexts of links that should be ignored.
+	skip map[string]bool
+	// Context of links in COMMENT and ARCHIVE subtrees.
+	headingContext map[*Heading]string
+	subtreeContext string
+	linkAbbrevs    orgLinkAbbrevs
+}
+
+type orgParsedHeading struct {
+	heading *Heading
+	parent  *Heading
+}
+
+// State of property drawer parser.
+const (
+	orgDrawerNone = iota
+	// Just after heading or planning line.
+	orgDrawerExpected
+	orgDrawerProperties
+	// LOGBOOK and other drawers.
+	orgDrawerOther
+)
+
+func newOrgParser(mainFile string, filter Filter, options *SourceOptions) *orgParser {
+	p := &orgParser{
+		options:  options,
+		mainFile: mainFile,
+	}
+	p.headingTree.init(filter)
+	names := options.OrgLinkProperties
+	if len(names) == 0 {
+		names = DefaultOrgLinkProperties
+	}
+	p.linkProperties = make(map[string]bool, len(names))
+	for _, name := range names {
+		p.linkProperties[strings.ToUpper(name)] = true
+	}
+	p.skip = make(map[string]bool, len(options.OrgSkip))
+	for _, context := range options.OrgSkip {
+		p.skip[context] = true
+	}
+	p.headingContext = map[*Heading]string{}
+	p.linkAbbrevs = orgLinkAbbrevs{}
+	if options.OrgLinkAbbrev != "" {
+		p.readLinkAbbrevFile(options.OrgLinkAbbrev)
+	}
+	if mainFile != "-" && mainFile != "" {
+		if path, err := filepath.Abs(mainFile); err == nil {
+			p.stack = append(p.stack, path)
+		}
+	}
+	return p
+}
+
+// Directory to resolve relative paths in file, empty for the main one.
+func (p *orgParser) baseDir(file string) string {
+	if file == "" {
+		file = p.mainFile
+	}
+	if file == "-" || file == "" {
+		return "."
+	}
+	return filepath.Dir(file)
+}
+
+// Process lines of file. The name is empty for the main file.
+// Level of headings is increased by levelShift.
+func (p *orgParser) parse(reader io.Reader, file string, levelShift int) error {
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	// #+LINK lines affect the whole file.
+	p.linkAbbrevs.scan(content)
+	scanner := newLineScanner(bytes.NewReader(content))
+	links := orgLinkScanner{parser: p, file: file}
+	lineNo := 0
+	var current *Heading
+	// File-level property drawer may be preceded by comments.
+	drawer := orgDrawerExpected
+	// Name of current block in lower case.
+	block := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+		context := ""
+		// Links in headings can not continue on next lines.
+		isHeading := false
+		if matchHeading := reHeading.FindStringSubmatch(line); matchHeading != nil {
+			isHeading = true
+			links.flush()
+			current = &Heading{LineNo: lineNo, RawText: matchHeading[2], File: file}
+			p.heading(len(matchHeading[1])+levelShift, current)
+			drawer = orgDrawerExpected
+			block = ""
+		} else if block != "" {
+			context = orgBlockContext(block)
+			if match := reOrgBlockEnd.FindStringSubmatch(line); match != nil && strings.EqualFold(match[1], block) {
+				block = ""
+			}
+		} else if drawer == orgDrawerOther {
+			context = OrgContextDrawer
+			if reOrgDrawerEnd.MatchString(line) {
+				drawer = orgDrawerNone
+			}
+		} else if drawer == orgDrawerProperties {
+			if reOrgDrawerEnd.MatchString(line) {
+				drawer = orgDrawerNone
+			} else if match := reOrgProperty.FindStringSubmatch(line); match != nil {
+				links.flush()
+				if p.property(current, strings.ToUpper(match[1]), match[2], file, lineNo) {
+					continue
+				}
+			}
+		} else if drawer == orgDrawerExpected && reOrgPropertiesBegin.MatchString(line) {
+			drawer = orgDrawerProperties
+		} else {
+			if drawer == orgDrawerExpected && !reOrgPlanning.MatchString(line) &&
+				!(current == nil && reOrgComment.MatchString(line)) {
+				drawer = orgDrawerNone
+			}
+			if match := reOrgBlockBegin.FindStringSubmatch(line); match != nil {
+				block = strings.ToLower(match[1])
+				context = orgBlockContext(block)
+			} else if match := reOrgDrawerBegin.FindStringSubmatch(line); match != nil &&
+				!strings.EqualFold(match[1], "END") {
+				drawer = orgDrawerOther
+				context = OrgContextDrawer
+			} else if matchKeyword := reOrgKeyword.FindStringSubmatch(line); matchKeyword != nil {
+				key := strings.ToUpper(matchKeyword[1])
+				links.flush()
+				p.keyword(key, matchKeyword[2], file)
+				if key == "LINK" {
+					// Template rather than link
+					continue
+				}
+			} else if reOrgCommentLine.MatchString(line) {
+				context = OrgContextComment
+			} else if reOrgFixedWidth.MatchString(line) {
+				context = OrgContextExample
+			}
+		}
+		links.scan(line, lineNo, context)
+		if isHeading {
+			links.flush()
+		}
+	}
+	links.flush()
+	return scanner.Err()
+}
+
+// Org links may span several lines, e.g. in filled paragraphs,
+// so text starting from unclosed "[[" is kept till following lines.
+type orgLinkScanner struct {
+	parser *orgParser
+	file   string
+	// Text since unclosed "[[".
+	text    string
+	lineNo  int
+	context string
+}
+
+// Protection against unbalanced brackets.
+var orgLinkMaxLines = 8
+
+func (s *orgLinkScanner) scan(line string, lineNo int, context string) {
+	if s.text != "" && (context != s.context || strings.TrimSpace(line) == "") {
+		s.flush()
+	}
+	text := line
+	if s.text != "" {
+		text = s.text + "\n" + line
+	} else {
+		s.lineNo = lineNo
+		s.context = context
+	}
+	open := strings.LastIndex(text, "[[")
+	if open >= 0 && (strings.Contains(text[open:], "]]") ||
+		strings.Count(text[open:], "\n") >= orgLinkMaxLines-1) {
+		open = -1
+	}
+	if open < 0 {
+		s.report(text, len(text))
+		s.text = ""
+		return
+	}
+	s.report(text, open)
+	s.lineNo += strings.Count(text[:open], "\n")
+	s.text = text[open:]
+}
+
+// Add links that are found in text before limit.
+func (s *orgLinkScanner) report(text string, limit int) {
+	for _, loc := range reLink.FindAllStringSubmatchIndex(text, -1) {
+		if loc[0] >= limit {
+			break
+		}
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		if link := s.parser.matchLink(match); link != nil {
+			if strings.Contains(link.Description, "\n") {
+				link.Description = strings.Join(strings.Fields(link.Description), " ")
+			}
+			link.LineNo = s.lineNo + strings.Count(text[:loc[0]], "\n")
+			link.File = s.file
+			link.Context = s.context
+			s.parser.addLink(link)
+		}
+	}
+}
+
+// Add links from text kept for unclosed "[[".
+func (s *orgLinkScanner) flush() {
+	if s.text != "" {
+		s.report(s.text, len(s.text))
+		s.text = ""
+	}
+}
+
+func (p *orgParser) heading(level int, h *Heading) {
+	parent := p.push(level, h)
+	p.parsed = append(p.parsed, orgParsedHeading{h, parent})
+	context := ""
+	if parent != nil {
+		context = p.headingContext[parent]
+	}
+	if context == "" {
+		// Final fields are parsed by finish, but skipped links
+		// should not be added to the tree.
+		parsed := Heading{RawText: h.RawText}
+		parsed.parse(p.currentTodoKeywords())
+		if parsed.Commented {
+			context = OrgContextCommented
+		} else if parsed.hasTag("ARCHIVE") {
+			context = OrgContextArchive
+		}
+	}
+	if context != "" {
+		p.headingContext[h] = context
+	}
+	p.subtreeContext = context
+}
+
+func (p *orgParser) addLink(link *Link) {
+	if link.Context == "" {
+		link.Context = p.subtreeContext
+	}
+	if p.skip[link.Context] {
+		return
+	}
+	p.add(link)
+}
+
+// Handle "#+KEY: value" line of file.
+func (p *orgParser) keyword(key string, value string, file string) {
+	switch key {
+	case "TODO", "SEQ_TODO", "TYP_TODO":
+		p.todoKeywords.add(value)
+	case "LINK":
+		p.linkAbbrevs.add(value)
+	case "FILETAGS":
+		p.fileTags = append(p.fileTags, parseOrgTags(value)...)
+	case "INCLUDE":
+		if p.options.OrgInclude {
+			p.include(value, file, false)
+		}
+	case "SETUPFILE":
+		if p.options.OrgInclude {
+			p.include(value, file, true)
+		}
+	}
+}
+
+// Handle a line of property drawer, heading is nil for file-level properties.
+// Returns true if links from the line are added, so it should not be
+// scanned for links once more.
+func (p *orgParser) property(h *Heading, key string, value string, file string, lineNo int) bool {
+	if p.linkProperties[strings.TrimSuffix(key, "+")] {
+		for _, link := range p.propertyLinks(value) {
+			link.LineNo = lineNo
+			link.File = file
+			link.Property = strings.TrimSuffix(key, "+")
+			p.addLink(link)
+		}
+		return true
+	}
+	if h == nil {
+		return false
+	}
+	switch key {
+	case "CUSTOM_ID":
+		h.CustomId = value
+	case "ID":
+		h.Id = value
+	}
+	return false
+}
+
+// Link from reLink match. Abbreviations in bracket links are expanded,
+// original text is kept as description if it is not specified.
+func (p *orgParser) matchLink(match []string) *Link {
+	if target := match[1]; target != "" && !reScheme.MatchString(target) {
+		url, ok := p.linkAbbrevs.expand(target)
+		if !ok || !reScheme.MatchString(url) {
+			return nil
+		}
+		description := match[2]
+		if description == "" {
+			description = target
+		}
+		return &Link{URL: url, Description: description}
+	}
+	return OrgLinkMatchIsUrl(match)
+}
+
+// Links from property value, they may be either plain URLs
+// (of configured schemes) or Org links.
+func (p *orgParser) propertyLinks(value string) []*Link {
+	var retval []*Link
+	for _, match := range reLink.FindAllStringSubmatch(value, -1) {
+		if link := p.matchLink(match); link != nil {
+			retval = append(retval, link)
+		}
+	}
+	return retval
+}
+
+// Keywords from #+TODO lines found so far or the default ones.
+func (p *orgParser) currentTodoKeywords() *orgTodoKeywords {
+	if !p.todoKeywords.empty() {
+		return &p.todoKeywords
+	}
+	if p.defaultKeywords == nil {
+		p.defaultKeywords = &orgTodoKeywords{}
+		p.defaultKeywords.add(strings.Join(DefaultOrgTodoKeywords, " "))
+	}
+	return p.defaultKeywords
+}
+
+// Parse headings when in-buffer settings from the whole file are known.
+func (p *orgParser) finish() {
+	keywords := p.currentTodoKeywords()
+	for _, entry := range p.parsed {
+		entry.heading.parse(keywords)
+		if entry.parent != nil {
+			entry.heading.inheritTags(entry.parent.allTags())
+		} else {
+			entry.heading.inheritTags(p.fileTags)
+		}
+	}
+}
+
+// Split `"file name" rest` or `file rest`.
+func splitIncludeValue(value string) (string, string) {
+	if strings.HasPrefix(value, `"`) {
+		if end := strings.Index(value[1:], `"`); end >= 0 {
+			return value[1 : end+1], strings.TrimSpace(value[end+2:])
+		}
+	}
+	if i := strings.IndexAny(value, " \t"); i >= 0 {
+		return value[:i], strings.TrimSpace(value[i:])
+	}
+	return value, ""
+}
+
+// Read links from #+INCLUDE file or in-buffer settings from #+SETUPFILE.
+// Failures are logged, only errors of the main file are fatal.
+func (p *orgParser) include(value string, file string, setup bool) {
+	target, params := splitIncludeValue(value)
+	if target == "" || strings.Contains(target, "://") {
+		return
+	}
+	if strings.Contains(target, "::") {
+		log.Printf("burl_links.orgParser: %s: include of a part of file is not supported", target)
+		return
+	}
+	if !setup && params != "" && !strings.HasPrefix(params, ":") {
+		// src, example, export blocks
+		return
+	}
+	path, err := expandHome(target)
+	if err != nil {
+		return
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(p.baseDir(file), path)
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	for _, visited := range p.stack {
+		if visited == path {
+			log.Printf("burl_links.orgParser: %s: include cycle", path)
+			return
+		}
+	}
+	if len(p.stack) >= orgIncludeDepthLimit {
+		log.Printf("burl_links.orgParser: %s: too deep include nesting", path)
+		return
+	}
+	content, stamp, err := readIncludeFile(path, p.options)
+	p.includes = append(p.includes, stamp)
+	if err != nil {
+		log.Printf("burl_links.orgParser: include: %v", err)
+		return
+	}
+	p.stack = append(p.stack, path)
+	defer func() { p.stack = p.stack[:len(p.stack)-1] }()
+	if setup {
+		p.setupFile(content, path)
+		return
+	}
+	levelShift := 0
+	if match := reIncludeLines.FindStringSubmatch(params); match != nil {
+		content = selectLines(content, match[1], match[2])
+	}
+	if match := reIncludeMinLevel.FindStringSubmatch(params); match != nil {
+		if minLevel, err := strconv.Atoi(match[1]); err == nil {
+			if current := orgMinLevel(content); current > 0 {
+				levelShift = minLevel - current
+			}
+		}
+	}
+	if err := p.parse(bytes.NewReader(content), path, levelShift); err != nil {
+		log.Printf("burl_links.orgParser: %s: %v", path, err)
+	}
+}
+
+// Global abbreviations are tracked as included file to notice changes.
+func (p *orgParser) readLinkAbbrevFile(path string) {
+	content, stamp, err := readIncludeFile(path, p.options)
+	p.includes = append(p.includes, stamp)
+	if err == nil {
+		err = p.linkAbbrevs.read(content)
+	}
+	if err != nil {
+		log.Printf("burl_links.orgParser: link abbreviations: %v", err)
+	}
+}
+
+// Only in-buffer settings are used from setup files, links are ignored.
+func (p *orgParser) setupFile(content []byte, path string) {
+	scanner := newLineScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if match := reOrgKeyword.FindStringSubmatch(scanner.Text()); match != nil {
+			p.keyword(strings.ToUpper(match[1]), match[2], path)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("burl_links.orgParser: %s: %v", path, err)
+	}
+}
+
+// Content is decoded the same way as for the main file, see decodeFile.
+func readIncludeFile(path string, options *SourceOptions) ([]byte, FileStamp, error) {
+	stamp := FileStamp{Path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, stamp, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, stamp, err
+	}
+	stamp.Size = info.Size()
+	stamp.ModTime = info.ModTime()
+	reader, err := decodeFile(path, file, options)
+	if err != nil {
+		return nil, stamp, fmt.Errorf("%s: %w", path, err)
+	}
+	defer reader.Close()
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, stamp, fmt.Errorf("%s: %w", path, err)
+	}
+	return content, stamp, nil
+}
+
+// Lines from first (1-based) to last, last excluded, the same as for
+// :lines "first-last" parameter of #+INCLUDE.
+// Line numbers of links are not adjusted to keep them valid.
+func selectLines(content []byte, first string, last string) []byte {
+	from, to := 1, 0
+	if n, err := strconv.Atoi(first); err == nil {
+		from = n
+	}
+	if n, err := strconv.Atoi(last); err == nil {
+		to = n
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	for i := range lines {
+		lineNo := i + 1
+		if lineNo < from || (to > 0 && lineNo >= to) {
+			// Keep empty lines to preserve line numbers
+			lines[i] = []byte("\n")
+		}
+	}
+	return bytes.Join(lines, nil)
+}
+
+func orgMinLevel(content []byte) int {
+	minLevel := 0
+	scanner := newLineScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		if match := reHeading.FindSubmatch(scanner.Bytes()); match != nil {
+			if level := len(match[1]); minLevel == 0 || level < minLevel {
+				minLevel = level
+			}
+		}
+	}
+	return minLevel
+}
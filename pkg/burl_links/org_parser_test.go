@@ -0,0 +1,316 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOrgInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	main := write("main.org", "* Top\n#+INCLUDE: \"part.org\" :minlevel 2\n"+
+		"#+include: main.org\n* Next\nhttps://next.example.com/\n")
+	part := write("part.org", "Before\n* Part\nhttps://part.example.com/\n")
+	options := DefaultSourceOptions.Copy()
+	options.OrgInclude = true
+	list := []TextLinkSource{WithOptions(OrgLinkSource(main), options)}
+
+	snapshot := UpdateFileGroup(nil, list, nil, nil)
+	if snapshot.Err != nil {
+		t.Fatalf("unexpected error: %v", snapshot.Err)
+	}
+	locations := snapshot.Index.Lookup([]string{"https://part.example.com/"})
+	if len(locations) != 1 {
+		t.Fatalf("link from included file not found: %+v", locations)
+	}
+	link := locations[0].Link
+	if link.File != part || link.LineNo != 3 {
+		t.Errorf("wrong location of included link: %+v", link)
+	}
+	path := locations[0].Path
+	if len(path) != 5 {
+		t.Fatalf("included heading should be nested: %+v", path)
+	}
+	top := path[2].(*TreeChildrenNode).Props.(*Heading)
+	nested := path[3].(*TreeChildrenNode).Props.(*Heading)
+	if top.RawText != "Top" || nested.RawText != "Part" || nested.File != part {
+		t.Errorf("unexpected headings %+v %+v", top, nested)
+	}
+	if !snapshot.HasFile(part) {
+		t.Errorf("included file should be allowed to visit")
+	}
+	if same := UpdateFileGroup(snapshot, list, nil, nil); same != snapshot {
+		t.Errorf("snapshot should be reused if included files are not modified")
+	}
+
+	part = write("part.org", "* Part\nhttps://changed.example.com/\n")
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(part, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	updated := UpdateFileGroup(snapshot, list, nil, nil)
+	if len(updated.Index.Lookup([]string{"https://changed.example.com/"})) != 1 {
+		t.Errorf("modified included file should be read again")
+	}
+
+	plain := UpdateFileGroup(nil, []TextLinkSource{OrgLinkSource(main)}, nil, nil)
+	if len(plain.Index.Lookup([]string{"https://part.example.com/"})) != 0 {
+		t.Errorf("includes should be ignored without the option")
+	}
+}
+
+func TestOrgHeadingMetadata(t *testing.T) {
+	input := `#+FILETAGS: :project:
+#+TODO: NEXT(n) WAIT(w@/!) | DONE(d) CANCELED(c)
+* NEXT [#A] Read the paper [1/3] :work:reading:
+SCHEDULED: <2022-05-01 Sun>
+:PROPERTIES:
+:CUSTOM_ID: paper
+:ID:       0b1d9c36-7e3a-4d55-9c7b-7b1f2c8f6a10
+:END:
+** CANCELED Subtask [50%]   :urgent:work:
+https://example.com/paper
+* TODO Not a keyword here
+https://example.com/todo
+`
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var headings []*Heading
+	var walk func(node TreeBaseNode)
+	walk = func(node TreeBaseNode) {
+		if children, ok := node.(*TreeChildrenNode); ok {
+			if h, ok := children.Props.(*Heading); ok {
+				headings = append(headings, h)
+			}
+			for _, child := range children.Children {
+				walk(child)
+			}
+		}
+	}
+	walk(tree)
+	expect := []*Heading{
+		{
+			LineNo: 3, RawText: "NEXT [#A] Read the paper [1/3] :work:reading:",
+			Level: 1, Todo: "NEXT", Priority: "A", Title: "Read the paper",
+			Tags: []string{"work", "reading"}, InheritedTags: []string{"project"},
+			CustomId: "paper", Id: "0b1d9c36-7e3a-4d55-9c7b-7b1f2c8f6a10",
+		},
+		{
+			LineNo: 9, RawText: "CANCELED Subtask [50%]   :urgent:work:",
+			Level: 2, Todo: "CANCELED", Done: true, Title: "Subtask",
+			Tags: []string{"urgent", "work"}, InheritedTags: []string{"project", "reading"},
+		},
+		{
+			LineNo: 11, RawText: "TODO Not a keyword here",
+			Level: 1, Title: "TODO Not a keyword here", InheritedTags: []string{"project"},
+		},
+	}
+	if !reflect.DeepEqual(headings, expect) {
+		for i, h := range headings {
+			t.Logf("%d: %+v", i, h)
+		}
+		t.Errorf("unexpected headings")
+	}
+}
+
+func TestOrgPropertyLinks(t *testing.T) {
+	input := `# -*- mode: org -*-
+:PROPERTIES:
+:ID:       file-node
+:ROAM_REFS: https://example.com/file [[https://example.com/bracket][Title]] @citekey
+:END:
+#+title: Notes
+* Heading
+:PROPERTIES:
+:url: https://example.com/heading
+:NOTE: https://example.com/mention
+:END:
+See https://example.com/heading again.
+`
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "https://example.com/file", LineNo: 4, Property: "ROAM_REFS"},
+		{URL: "https://example.com/bracket", Description: "Title", LineNo: 4, Property: "ROAM_REFS"},
+		{URL: "https://example.com/heading", LineNo: 9, Property: "URL"},
+		{URL: "https://example.com/mention", LineNo: 10},
+		{URL: "https://example.com/heading", LineNo: 12},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
+
+func TestOrgLinkContext(t *testing.T) {
+	input := `* Notes
+https://example.com/text
+# https://example.com/comment
+#+begin_src sh
+curl https://example.com/src
+#+end_src
+#+BEGIN_EXAMPLE
+https://example.com/example
+#+END_EXAMPLE
+: https://example.com/fixed
+:LOGBOOK:
+- Note https://example.com/drawer
+:END:
+* TODO COMMENT Draft
+https://example.com/commented
+** Child
+https://example.com/commented-child
+* Old :ARCHIVE:
+:PROPERTIES:
+:URL: https://example.com/archive
+:END:
+* Last
+https://example.com/last
+`
+	extract := func(skip []string) map[string]string {
+		options := DefaultSourceOptions.Copy()
+		options.OrgSkip = skip
+		tree, err := OrgLinkSource("test.org").ExtractWithOptions(strings.NewReader(input), nil, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual := map[string]string{}
+		ForEachLink(tree, func(link *Link) bool {
+			actual[link.URL] = link.Context
+			return true
+		})
+		return actual
+	}
+	expect := map[string]string{
+		"https://example.com/text":            "",
+		"https://example.com/comment":         OrgContextComment,
+		"https://example.com/src":             OrgContextSrc,
+		"https://example.com/example":         OrgContextExample,
+		"https://example.com/fixed":           OrgContextExample,
+		"https://example.com/drawer":          OrgContextDrawer,
+		"https://example.com/commented":       OrgContextCommented,
+		"https://example.com/commented-child": OrgContextCommented,
+		"https://example.com/archive":         OrgContextArchive,
+		"https://example.com/last":            "",
+	}
+	if actual := extract(nil); !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%v != %v", actual, expect)
+	}
+	skipped := extract(OrgLinkContexts)
+	if !reflect.DeepEqual(skipped, map[string]string{
+		"https://example.com/text": "",
+		"https://example.com/last": "",
+	}) {
+		t.Errorf("links should be skipped: %v", skipped)
+	}
+}
+
+func TestOrgLinkAbbrev(t *testing.T) {
+	dir, err := ioutil.TempDir("", "burl_links")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	abbrevFile := filepath.Join(dir, "abbrev.txt")
+	abbrevs := "# global abbreviations\nwiki https://en.wikipedia.org/wiki/\n#+LINK: gh https://example.com/global/%s\n"
+	if err := ioutil.WriteFile(abbrevFile, []byte(abbrevs), 0644); err != nil {
+		t.Fatal(err)
+	}
+	input := `* Links
+[[gh:maxnikulin/burl]] and [[GH:maxnikulin/linkremark][LR]]
+[[wiki:Org-mode]] [[search:a b]] [[unknown:tag]]
+#+LINK: gh https://github.com/%s
+#+link: search https://duckduckgo.com/?q=%h
+`
+	options := DefaultSourceOptions.Copy()
+	options.OrgLinkAbbrev = abbrevFile
+	tree, err := OrgLinkSource("test.org").ExtractWithOptions(strings.NewReader(input), nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "https://github.com/maxnikulin/burl", Description: "gh:maxnikulin/burl", LineNo: 2},
+		{URL: "https://github.com/maxnikulin/linkremark", Description: "LR", LineNo: 2},
+		{URL: "https://en.wikipedia.org/wiki/Org-mode", Description: "wiki:Org-mode", LineNo: 3},
+		{URL: "https://duckduckgo.com/?q=a%20b", Description: "search:a b", LineNo: 3},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+	if includes := treeIncludes(tree); len(includes) != 1 || includes[0].Path != abbrevFile {
+		t.Errorf("abbreviation file should be tracked for changes: %+v", includes)
+	}
+}
+
+func TestOrgMultiLineLinks(t *testing.T) {
+	input := `* Heading
+Filled paragraph with [[https://example.com/wrapped][a description
+that is wrapped]] and https://example.com/plain on the same line.
+Unclosed [[https://example.com/unclosed][bracket
+
+is reported as plain link, [[https://example.com/other][another
+  wrapped]] link. ` + strings.Repeat("x", 100*1024) + ` https://example.com/long
+`
+	tree, err := OrgLinkSource("test.org").Extract(strings.NewReader(input), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual []Link
+	ForEachLink(tree, func(link *Link) bool {
+		actual = append(actual, *link)
+		return true
+	})
+	expect := []Link{
+		{URL: "https://example.com/wrapped", Description: "a description that is wrapped", LineNo: 2},
+		{URL: "https://example.com/plain", LineNo: 3},
+		{URL: "https://example.com/unclosed", LineNo: 4},
+		{URL: "https://example.com/other", Description: "another wrapped", LineNo: 6},
+		{URL: "https://example.com/long", LineNo: 7},
+	}
+	if !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%+v != %+v", actual, expect)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_links
+
+import (
+	"io"
+	"reflect"
+	"strconv"
+)
+
+// Settings of particular sources. Command line flags changing them
+// affect sources specified after them, see AddSourceFlags.
+type SourceOptions struct {
+	// Globs for files in directories, DefaultDirInclude if empty.
+	DirInclude []string
+	DirExclude []string
+	// Read #+INCLUDE and #+SETUPFILE files.
+	OrgInclude bool
+	// Org properties with links, DefaultOrgLinkProperties if empty.
+	OrgLinkProperties []string
+	// Contexts of links to ignore, see OrgLinkContexts,
+	// links in other contexts are marked by Link.Context.
+	OrgSkip []string
+	// File with link abbreviations in addition to #+LINK lines.
+	OrgLinkAbbrev string
+	// Run GpgProgram for ".gpg" files.
+	Decrypt bool
+	// Encoding of files without BOM or coding cookie, UTF-8 if empty.
+	Encoding string
+}
+
+var DefaultSourceOptions = SourceOptions{}
+
+func (o *SourceOptions) IsDefault() bool {
+	return o.Equal(&DefaultSourceOptions)
+}
+
+func (o *SourceOptions) Equal(other *SourceOptions) bool {
+	if o == nil {
+		o = &DefaultSourceOptions
+	}
+	if other == nil {
+		other = &DefaultSourceOptions
+	}
+	return reflect.DeepEqual(*o, *other)
+}
+
+func (o *SourceOptions) Copy() *SourceOptions {
+	c := *o
+	c.DirInclude = append([]string(nil), o.DirInclude...)
+	c.DirExclude = append([]string(nil), o.DirExclude...)
+	c.OrgLinkProperties = append([]string(nil), o.OrgLinkProperties...)
+	c.OrgSkip = append([]string(nil), o.OrgSkip...)
+	return &c
+}
+
+// Flags to restore options in a generated wrapper script. Since options
+// are sticky, every value is specified explicitly.
+func (o *SourceOptions) Args() []string {
+	retval := make([]string, 0, 8+len(o.DirInclude)+len(o.DirExclude)+
+		len(o.OrgLinkProperties)+len(o.OrgSkip))
+	retval = append(retval, "--dir-include=")
+	for _, glob := range o.DirInclude {
+		retval = append(retval, "--dir-include="+glob)
+	}
+	retval = append(retval, "--dir-exclude=")
+	for _, glob := range o.DirExclude {
+		retval = append(retval, "--dir-exclude="+glob)
+	}
+	retval = append(retval, "--org-include="+strconv.FormatBool(o.OrgInclude))
+	retval = append(retval, "--org-link-property=")
+	for _, name := range o.OrgLinkProperties {
+		retval = append(retval, "--org-link-property="+name)
+	}
+	retval = append(retval, "--org-skip=")
+	for _, context := range o.OrgSkip {
+		retval = append(retval, "--org-skip="+context)
+	}
+	retval = append(retval, "--org-link-abbrev="+o.OrgLinkAbbrev)
+	retval = append(retval, "--decrypt="+strconv.FormatBool(o.Decrypt))
+	retval = append(retval, "--encoding="+o.Encoding)
+	return retval
+}
+
+// Sources that support SourceOptions.
+type OptionsExtractor interface {
+	ExtractWithOptions(file io.Reader, filter Filter, options *SourceOptions) (*TreeChildrenNode, error)
+}
+
+// Source that represents a list of other ones, e.g. a directory.
+type ExpandableLinkSource interface {
+	Expand(options *SourceOptions) ([]TextLinkSource, error)
+}
+
+// Wrapper for sources with non-default options.
+type SourceWithOptions struct {
+	TextLinkSource
+	Options *SourceOptions
+}
+
+var _ TextLinkSource = (*SourceWithOptions)(nil)
+
+func WithOptions(src TextLinkSource, options *SourceOptions) TextLinkSource {
+	if options.IsDefault() {
+		return src
+	}
+	return &SourceWithOptions{src, options}
+}
+
+func (s *SourceWithOptions) Clone(name string) TextLinkSource {
+	return &SourceWithOptions{s.TextLinkSource.Clone(name), s.Options}
+}
+
+func (s *SourceWithOptions) Extract(file io.Reader, filter Filter) (*TreeChildrenNode, error) {
+	if extractor, ok := s.TextLinkSource.(OptionsExtractor); ok {
+		return extractor.ExtractWithOptions(file, filter, s.Options)
+	}
+	return s.TextLinkSource.Extract(file, filter)
+}
+
+// Strip SourceWithOptions wrapper, options are never nil.
+func UnwrapSource(src TextLinkSource) (TextLinkSource, *SourceOptions) {
+	if wrapper, ok := src.(*SourceWithOptions); ok {
+		return wrapper.TextLinkSource, wrapper.Options
+	}
+	return src, &DefaultSourceOptions
+}
+
+// Replace directories and similar sources by files. Failed sources
+// are reported as SourceErrors, the list is still usable.
+func ExpandSources(list []TextLinkSource) ([]TextLinkSource, error) {
+	var failures SourceErrors
+	retval := make([]TextLinkSource, 0, len(list))
+	for _, src := range list {
+		inner, options := UnwrapSource(src)
+		expandable, ok := inner.(ExpandableLinkSource)
+		if !ok {
+			retval = append(retval, src)
+			continue
+		}
+		children, err := expandable.Expand(options)
+		for _, child := range children {
+			retval = append(retval, WithOptions(child, options))
+		}
+		if err != nil {
+			failures = append(failures, sourceError(src, err))
+		}
+	}
+	if failures != nil {
+		return retval, failures
+	}
+	return retval, nil
+}
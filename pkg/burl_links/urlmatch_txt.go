@@ -15,7 +15,19 @@
 
 package burl_links
 
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Legacy patterns that do not recognize query, fragment, port, userinfo,
+// and IPv6 hosts.
 // https://gist.github.com/dperini/729294
+//
+// Deprecated: plain text sources use a scanner that is available
+// through ExtractTextUrls.
 const (
 	UrlPatternSchemaSlashes = `(?:([[:alpha:]]+:(\/\/)?))`
 	//patUserPassword = `(?:(\S+)(?::(\S*))?@)`
@@ -27,6 +39,9 @@ const (
 	UrlPatternFull      = UrlPatternSchemaSlashes + "?" + UrlPatternDomainTLD + "?" + patPath + "?"
 )
 
+// Indices of UrlPatternFull submatches.
+//
+// Deprecated: see UrlPatternFull.
 const (
 	NPatFull = iota
 	NPatSchema
@@ -36,6 +51,7 @@ const (
 	NPatPath
 )
 
+// Deprecated: see UrlPatternFull.
 func MatchIsUrl(match []string) bool {
 	if len(match) <= 0 {
 		return false
@@ -62,3 +78,91 @@ func MatchIsUrl(match []string) bool {
 	}
 	return true
 }
+
+// Start of URL in plain text, updated by UpdateRe.
+var reTxtUrlScheme = regexp.MustCompile(makeTxtUrlSchemeReStr(reSchemeStr))
+
+func makeTxtUrlSchemeReStr(schemeStr string) string {
+	return `\b(?:` + schemeStr + `):`
+}
+
+// Characters that can not appear in URLs in addition to spaces
+// and control characters, see RFC 3986 and https://url.spec.whatwg.org/
+const txtUrlExcluded = "<>\"`{}|\\^"
+
+// Characters that are allowed inside URL but likely
+// belong to surrounding text when they are at the end.
+const txtUrlTrailingPunct = ".,:;!?'*"
+
+func isTxtUrlRune(r rune) bool {
+	return r != utf8.RuneError && !unicode.IsSpace(r) && !unicode.IsControl(r) &&
+		!strings.ContainsRune(txtUrlExcluded, r) &&
+		// Quotation marks like “” and «»
+		!unicode.In(r, unicode.Pi, unicode.Pf)
+}
+
+// Strip trailing punctuation and closing brackets without pairs,
+// so "(see https://en.wikipedia.org/wiki/Go_(language))." gives
+// "https://en.wikipedia.org/wiki/Go_(language)".
+func trimTxtUrl(url string) string {
+	for url != "" {
+		r, size := utf8.DecodeLastRuneInString(url)
+		switch {
+		case r < utf8.RuneSelf && strings.ContainsRune(txtUrlTrailingPunct, r):
+		case r == ')' && strings.Count(url, "(") < strings.Count(url, ")"):
+		case r == ']' && strings.Count(url, "[") < strings.Count(url, "]"):
+		case r >= utf8.RuneSelf && unicode.IsPunct(r):
+		default:
+			return url
+		}
+		url = url[:len(url)-size]
+	}
+	return url
+}
+
+// URLs with schemes from SchemeVariants found in a line of plain text.
+// Query, fragment, port, userinfo, and IPv6 hosts are recognized,
+// non-ASCII characters are allowed as in IRIs.
+func findTextUrls(line string) []string {
+	return scanTextUrls(line, reTxtUrlScheme)
+}
+
+// URLs in a line of plain text starting at matches of reStart,
+// that should match a scheme with colon and optionally a part of URL after it.
+func scanTextUrls(line string, reStart *regexp.Regexp) []string {
+	var retval []string
+	for offset := 0; offset < len(line); {
+		loc := reStart.FindStringIndex(line[offset:])
+		if loc == nil {
+			break
+		}
+		start := offset + loc[0]
+		end := offset + loc[1]
+		for end < len(line) {
+			r, size := utf8.DecodeRuneInString(line[end:])
+			if !isTxtUrlRune(r) {
+				break
+			}
+			end += size
+		}
+		offset = end
+		url := trimTxtUrl(line[start:end])
+		// Trailing ":" of scheme might be stripped.
+		schemeLength := strings.IndexByte(line[start:], ':') + 1
+		if len(url) <= schemeLength {
+			continue
+		}
+		rest := url[schemeLength:]
+		if strings.HasPrefix(rest, "//") {
+			authority := rest[2:]
+			if i := strings.IndexAny(authority, "/?#"); i >= 0 {
+				authority = authority[:i]
+			}
+			if authority == "" {
+				continue
+			}
+		}
+		retval = append(retval, url)
+	}
+	return retval
+}
@@ -16,6 +16,7 @@
 package burl_links
 
 import (
+	"reflect"
 	"regexp"
 	"testing"
 )
@@ -103,3 +104,71 @@ func TestMatchIsUrl(t *testing.T) {
 		})
 	}
 }
+
+// Cases of the legacy patterns, recognizer of plain text URLs
+// requires a scheme and an authority after "//".
+func TestFindTextUrlsLegacyCases(t *testing.T) {
+	cases := []struct {
+		word   string
+		expect []string
+	}{
+		// UrlPatternSchemaSlashes matched
+		{`"http://"`, nil},
+		{`abc`, nil},
+		{`ftp`, nil},
+		{`https://`, nil},
+		// UrlPatternDomainTLD matched hosts without scheme
+		{"www.geocities.com", nil},
+		{"12", nil},
+		{" почта.рф", nil},
+		{"abc", nil},
+		// UrlPatternFull and MatchIsUrl
+		{"http://www.geocities.com/~user/index.html",
+			[]string{"http://www.geocities.com/~user/index.html"}},
+		{"(http://www.geocities.com/~user/index.html)",
+			[]string{"http://www.geocities.com/~user/index.html"}},
+		{" absf asdf http://www.geocities.com/~user/index.html word end",
+			[]string{"http://www.geocities.com/~user/index.html"}},
+		{"src/FFmpeg.h", nil},
+	}
+	for _, c := range cases {
+		if actual := findTextUrls(c.word); !reflect.DeepEqual(actual, c.expect) {
+			t.Errorf("%q: %q != %q", c.word, actual, c.expect)
+		}
+	}
+}
+
+func TestFindTextUrls(t *testing.T) {
+	cases := []struct {
+		line   string
+		expect []string
+	}{
+		{"see https://example.com/search?q=x&lang=en#frag.",
+			[]string{"https://example.com/search?q=x&lang=en#frag"}},
+		{"http://user:pw@example.com:8080/path, http://[2001:db8::1]:80/x;",
+			[]string{"http://user:pw@example.com:8080/path", "http://[2001:db8::1]:80/x"}},
+		{"(see https://en.wikipedia.org/wiki/Go_(language)).",
+			[]string{"https://en.wikipedia.org/wiki/Go_(language)"}},
+		{"<https://example.com/a> \"https://example.com/b\" «https://пример.рф/путь»",
+			[]string{"https://example.com/a", "https://example.com/b", "https://пример.рф/путь"}},
+		{"Prefer https: or https:// over ftp://example.com/ and www.example.com", nil},
+		{"mid:abc@example.com? doi:10.1000/xyz", []string{"mid:abc@example.com", "doi:10.1000/xyz"}},
+		{"xhttps://example.com/ nohttps", nil},
+	}
+	for _, c := range cases {
+		if actual := findTextUrls(c.line); !reflect.DeepEqual(actual, c.expect) {
+			t.Errorf("%q: %q != %q", c.line, actual, c.expect)
+		}
+	}
+}
+
+func TestFindTextUrlsSchemeVariants(t *testing.T) {
+	if err := UpdateRe([]string{"ftp"}); err != nil {
+		t.Fatal(err)
+	}
+	defer UpdateRe(SchemeVariants)
+	actual := findTextUrls("https://example.com/ ftp://example.com/pub/")
+	if expect := []string{"ftp://example.com/pub/"}; !reflect.DeepEqual(actual, expect) {
+		t.Errorf("%q != %q", actual, expect)
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Query parameters that do not affect content of pages, they are removed
+// when URLs are compared. Shell-style patterns are allowed,
+// names are case insensitive.
+var TrackingParams = []string{
+	"utm_*", "fbclid", "gclid", "dclid", "gbraid", "wbraid", "msclkid",
+	"yclid", "mc_cid", "mc_eid", "_hsenc", "_hsmi", "igshid",
+}
+
+var DefaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// RFC 3986 Appendix B
+var reUriParts = regexp.MustCompile(`^(?:([^:/?#]+):)?(?://([^/?#]*))?([^?#]*)(?:\?([^#]*))?(?:#(.*))?$`)
+
+const (
+	nUriScheme = 1 + iota
+	nUriAuthority
+	nUriPath
+	nUriQuery
+	nUriFragment
+)
+
+// Components of URI, Has* fields distinguish empty and absent parts.
+type uriParts struct {
+	Scheme       string
+	HasAuthority bool
+	Authority    string
+	Path         string
+	HasQuery     bool
+	Query        string
+	HasFragment  bool
+	Fragment     string
+}
+
+func splitUri(uri string) *uriParts {
+	loc := reUriParts.FindStringSubmatchIndex(uri)
+	if loc == nil {
+		return nil
+	}
+	part := func(n int) (string, bool) {
+		if loc[2*n] < 0 {
+			return "", false
+		}
+		return uri[loc[2*n]:loc[2*n+1]], true
+	}
+	var p uriParts
+	p.Scheme, _ = part(nUriScheme)
+	p.Authority, p.HasAuthority = part(nUriAuthority)
+	p.Path, _ = part(nUriPath)
+	p.Query, p.HasQuery = part(nUriQuery)
+	p.Fragment, p.HasFragment = part(nUriFragment)
+	return &p
+}
+
+func (p *uriParts) String() string {
+	var b strings.Builder
+	if p.Scheme != "" {
+		b.WriteString(p.Scheme)
+		b.WriteByte(':')
+	}
+	if p.HasAuthority {
+		b.WriteString("//")
+		b.WriteString(p.Authority)
+	}
+	b.WriteString(p.Path)
+	if p.HasQuery {
+		b.WriteByte('?')
+		b.WriteString(p.Query)
+	}
+	if p.HasFragment {
+		b.WriteByte('#')
+		b.WriteString(p.Fragment)
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func unhex(c byte) (byte, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0', true
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10, true
+	case 'A' <= c && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// Decode percent-encoded unreserved characters and use upper case
+// for other escapes, RFC 3986 section 6.2.2.
+func normalizePercent(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			hi, okHi := unhex(s[i+1])
+			lo, okLo := unhex(s[i+2])
+			if okHi && okLo {
+				if c := hi<<4 | lo; isUnreserved(c) {
+					b.WriteByte(c)
+				} else {
+					b.WriteByte('%')
+					b.WriteByte(hexDigits[hi])
+					b.WriteByte(hexDigits[lo])
+				}
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// RFC 3986 section 5.2.4, empty segments are preserved.
+func removeDotSegments(p string) string {
+	if !strings.Contains(p, ".") {
+		return p
+	}
+	segments := strings.Split(p, "/")
+	out := make([]string, 0, len(segments))
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case ".":
+		case "..":
+			if len(out) > 1 || len(out) == 1 && out[0] != "" {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, segment)
+			continue
+		}
+		if last {
+			out = append(out, "")
+		}
+	}
+	return strings.Join(out, "/")
+}
+
+func isTrackingParam(name string) bool {
+	name = strings.ToLower(normalizePercent(name))
+	for _, pattern := range TrackingParams {
+		if matched, err := path.Match(strings.ToLower(pattern), name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Drop empty and tracking parameters, sort the rest.
+func normalizeQuery(query string) string {
+	params := strings.Split(query, "&")
+	kept := params[:0]
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+		name := param
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name = param[:i]
+		}
+		if !isTrackingParam(name) {
+			kept = append(kept, normalizeIri(normalizePercent(param)))
+		}
+	}
+	sort.Strings(kept)
+	return strings.Join(kept, "&")
+}
+
+func normalizeAuthority(scheme, authority string) string {
+	userinfo := ""
+	if i := strings.LastIndexByte(authority, '@'); i >= 0 {
+		userinfo, authority = authority[:i+1], authority[i+1:]
+	}
+	host, port := authority, ""
+	if i := strings.LastIndexByte(authority, ':'); i >= 0 && !strings.Contains(authority[i:], "]") {
+		host, port = authority[:i], authority[i+1:]
+	}
+	host = strings.TrimSuffix(normalizeHost(normalizePercent(host)), ".")
+	if port == DefaultPorts[scheme] {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	return normalizePercent(userinfo) + host
+}
+
+// Normalized form of URL: scheme and host in lower case, internationalized
+// domain names in punycode, no default port, normalized percent-encoding
+// (non-ASCII characters in NFC) and dot segments, no tracking parameters
+// (see TrackingParams), and sorted query. Unparsable URLs are returned as is.
+func Normalize(url string) string {
+	p := splitUri(url)
+	if p == nil || p.Scheme == "" {
+		return url
+	}
+	p.Scheme = strings.ToLower(p.Scheme)
+	if !p.HasAuthority {
+		// Opaque URI, e.g. "mid:" or "doi:"
+		return p.String()
+	}
+	p.Authority = normalizeAuthority(p.Scheme, p.Authority)
+	p.Path = removeDotSegments(normalizeIri(normalizePercent(p.Path)))
+	if p.Path == "" {
+		p.Path = "/"
+	}
+	if p.HasQuery {
+		p.Query = normalizeQuery(p.Query)
+		p.HasQuery = p.Query != ""
+	}
+	if p.HasFragment {
+		p.Fragment = normalizeIri(normalizePercent(p.Fragment))
+		p.HasFragment = p.Fragment != ""
+	}
+	return p.String()
+}
+
+// Normalized URL with site-specific rewrite rules applied
+// (see UserRewriteRules and DefaultRewriteRules), identifiers
+// like "doi:" are replaced by URLs of resolvers, references
+// to mail messages are replaced by "mid:" URLs.
+func Canonical(url string) string {
+	return canonicalMessageId(canonicalIdentifier(applyRewriteRules(Normalize(url))))
+}
+
+// Key to compare URLs. Beside Canonical, "http:" and "https:"
+// are considered equivalent as well as URLs with and without
+// trailing "/", "?", "&", "#" like in UrlVariants. DOI is case insensitive.
+func CanonicalKey(url string) string {
+	key := Canonical(url)
+	if strings.HasPrefix(key, "http:") {
+		key = "https:" + key[len("http:"):]
+	}
+	if strings.HasPrefix(key, doiResolver) {
+		key = doiResolver + strings.ToLower(key[len(doiResolver):])
+	}
+	if p := splitUri(key); p != nil && p.HasAuthority && !p.HasQuery && !p.HasFragment {
+		key = strings.TrimRight(key, "/")
+	}
+	return key
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct{ url, expect string }{
+		{"HTTPS://Example.COM:443/a?utm_source=x", "https://example.com/a"},
+		{"http://example.com:8080", "http://example.com:8080/"},
+		{"https://example.com/a/./b/../c/%7euser/%2f%c3%a9", "https://example.com/a/c/~user/%2F%C3%A9"},
+		{"https://example.com/p?b=2&fbclid=z&a=1&&UTM_Medium=y#frag", "https://example.com/p?a=1&b=2#frag"},
+		{"https://user@[2001:DB8::1]:443/?#", "https://user@[2001:db8::1]/"},
+		{"MID:Abc@Example.COM", "mid:Abc@Example.COM"},
+		{"not a url", "not a url"},
+	}
+	for _, c := range cases {
+		if actual := Normalize(c.url); actual != c.expect {
+			t.Errorf("%q: %q != %q", c.url, actual, c.expect)
+		}
+	}
+}
+
+func TestCanonicalKey(t *testing.T) {
+	same := [][]string{
+		{"https://example.com/a?utm_source=feed", "http://example.com/a/", "https://EXAMPLE.com/a#"},
+		{"https://example.com", "https://example.com/", "http://example.com:80/?"},
+		{"https://example.com/s?q=x&gclid=1", "https://example.com/s?q=x"},
+	}
+	for _, group := range same {
+		expect := CanonicalKey(group[0])
+		for _, url := range group[1:] {
+			if actual := CanonicalKey(url); actual != expect {
+				t.Errorf("%q: %q != %q", url, actual, expect)
+			}
+		}
+	}
+	if CanonicalKey("https://example.com/s?q=x") == CanonicalKey("https://example.com/s?q=y") {
+		t.Errorf("query should be significant")
+	}
+}
@@ -0,0 +1,149 @@
+#!/usr/bin/env python3
+# Generate unicode_tables.go with data for canonical decomposition
+# and composition from the Unicode character database bundled with Python
+# and testdata/NormalizationTest.txt with conformance cases in the format
+# of the file from the Unicode character database.
+# The version is pinned to get reproducible tables, run the script
+# by a Python release with the same database.
+
+import sys
+import unicodedata
+
+UNICODE_VERSION = "14.0.0"
+
+HEADER = open("canonical.go").read().split("package", 1)[0]
+
+
+def canonical_decompositions():
+    items = []
+    for cp in range(0x110000):
+        decomposition = unicodedata.decomposition(chr(cp))
+        if not decomposition or decomposition.startswith("<"):
+            continue
+        # Hangul syllables are decomposed algorithmically
+        if 0xAC00 <= cp <= 0xD7A3:
+            continue
+        parts = [int(x, 16) for x in decomposition.split()]
+        assert len(parts) in (1, 2), hex(cp)
+        items.append((cp, parts[0], parts[1] if len(parts) == 2 else 0))
+    return items
+
+
+def composition_pairs():
+    pairs = []
+    for cp in range(0x110000):
+        c = chr(cp)
+        decomposition = unicodedata.decomposition(c)
+        if not decomposition or decomposition.startswith("<"):
+            continue
+        parts = [int(x, 16) for x in decomposition.split()]
+        # Hangul syllables are composed algorithmically
+        if len(parts) != 2 or 0xAC00 <= cp <= 0xD7A3:
+            continue
+        # Composition exclusions
+        if unicodedata.normalize("NFC", chr(parts[0]) + chr(parts[1])) != c:
+            continue
+        pairs.append((parts[0], parts[1], cp))
+    return pairs
+
+
+def combining_ranges():
+    ranges = []
+    for cp in range(0x110000):
+        ccc = unicodedata.combining(chr(cp))
+        if not ccc:
+            continue
+        if ranges and ranges[-1][1] == cp - 1 and ranges[-1][2] == ccc:
+            ranges[-1][1] = cp
+        else:
+            ranges.append([cp, cp, ccc])
+    return ranges
+
+
+def normalization_line(chars, comment=""):
+    forms = [chars] + [unicodedata.normalize(form, chars)
+                       for form in ("NFC", "NFD", "NFKC", "NFKD")]
+    fields = [" ".join("%04X" % ord(c) for c in text) for text in forms]
+    return ";".join(fields) + ";" + (" # " + comment if comment else "")
+
+
+def normalization_tests():
+    lines = ["# Conformance cases generated by gen_unicode_tables.py",
+             "# from Unicode %s, the format is described" % UNICODE_VERSION,
+             "# in NormalizationTest.txt of the Unicode character database.",
+             "# Part 1 includes characters with canonical decompositions",
+             "# and a part of Hangul syllables, other characters are NFC.",
+             "#",
+             "@Part0 # Specific cases"]
+    for text in ("\u1E0A", "\u1E0C", "\u1E0A\u0323", "\u1E0C\u0307",
+                 "D\u0307\u0323", "D\u0323\u0307", "\u1E0A\u031B",
+                 "\u1E0C\u031B", "\u1E0A\u031B\u0323", "\u1E0C\u031B\u0307",
+                 "\u00C8", "\u0112", "E\u0300", "\u1E14", "\u0112\u0300",
+                 "\u1E14\u0304", "E\u0304\u0300", "E\u0300\u0304",
+                 "\u05B8\u05B9\u05B1\u0591\u05C3\u05B0\u05AC\u059F",
+                 "\u0592\u05B7\u05BC\u05A5\u05B0\u05C0\u05C4\u05AD",
+                 "\u1100\uAC00\u11A8", "\u1100\uAC00\u11A8\u11A8",
+                 "\uAC00\u11A7", "\u1100\u1161\u11A7", "\u1100\u0300\u1161",
+                 "a\u0301\u0301", "\u0B47\u0300\u0B3E", "\u1100\u0300\u1161"):
+        lines.append(normalization_line(text))
+    lines.append("@Part1 # Character by character test")
+    for cp in range(0x110000):
+        c = chr(cp)
+        decomposition = unicodedata.decomposition(c)
+        if decomposition and not decomposition.startswith("<"):
+            lines.append(normalization_line(c, unicodedata.name(c)))
+        elif 0xAC00 <= cp <= 0xD7A3 and (cp - 0xAC00) % 28 in (0, 1, 27):
+            lines.append(normalization_line(c, unicodedata.name(c)))
+    lines.append("@Part2 # Canonical order test")
+    for cp in range(0x110000):
+        c = chr(cp)
+        if not unicodedata.combining(c):
+            continue
+        name = unicodedata.name(c, "U+%04X" % cp)
+        lines.append(normalization_line(
+            "a\u0315\u0300\u05AE" + c + "b", name))
+    lines.append("@Part3 # Composition blocked by marks and starters")
+    for first, second, composed in composition_pairs():
+        lines.append(normalization_line(
+            chr(first) + "\u0316" + chr(second), unicodedata.name(chr(composed))))
+    lines.append("")
+    return lines
+
+
+def chunks(items, size):
+    for i in range(0, len(items), size):
+        yield items[i:i + size]
+
+
+def main():
+    if unicodedata.unidata_version != UNICODE_VERSION:
+        sys.exit("Unicode %s is required, Python has %s"
+                 % (UNICODE_VERSION, unicodedata.unidata_version))
+    lines = [HEADER.rstrip("\n"), "",
+             "// Code generated by gen_unicode_tables.py from Unicode %s; DO NOT EDIT."
+             % UNICODE_VERSION, "",
+             "package burl_url", "",
+             "// Canonical decompositions: character, first and second (zero for singletons)",
+             "// characters, they may be decomposed further.",
+             "var canonicalDecompositions = [...][3]rune{"]
+    for chunk in chunks(canonical_decompositions(), 4):
+        lines.append("\t" + " ".join("{0x%04X, 0x%04X, 0x%04X}," % item for item in chunk))
+    lines += ["}", "",
+             "// Primary composites: starter, combining character, composed character.",
+             "var compositionPairs = [...][3]rune{"]
+    for chunk in chunks(composition_pairs(), 4):
+        lines.append("\t" + " ".join("{0x%04X, 0x%04X, 0x%04X}," % item for item in chunk))
+    lines += ["}", "",
+              "// Ranges of characters with non-zero canonical combining class.",
+              "var combiningClassRanges = [...]combiningClassRange{"]
+    for chunk in chunks(combining_ranges(), 4):
+        lines.append("\t" + " ".join("{0x%04X, 0x%04X, %d}," % tuple(item) for item in chunk))
+    lines += ["}", ""]
+    with open("unicode_tables.go", "w") as f:
+        f.write("\n".join(lines))
+    with open("testdata/NormalizationTest.txt", "w") as f:
+        f.write("\n".join(normalization_tests()))
+
+
+if __name__ == "__main__":
+    main()
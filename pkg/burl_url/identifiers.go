@@ -0,0 +1,148 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Persistent identifiers are mapped to URLs of their resolvers:
+//   - "doi:", "info:doi/", doi.org, dx.doi.org to https://doi.org/,
+//     case is preserved, DOI is converted to lower case by CanonicalKey;
+//   - "arxiv:" to https://arxiv.org/abs/;
+//   - "isbn:", "urn:isbn:", ISBN-10 and ISBN-13, isbnsearch.org,
+//     worldcat.org to https://openlibrary.org/isbn/ with ISBN-13;
+//   - "pmid:" and ncbi.nlm.nih.gov/pubmed to https://pubmed.ncbi.nlm.nih.gov/.
+func canonicalIdentifier(u string) string {
+	p := splitUri(u)
+	if p == nil {
+		return u
+	}
+	opaque := u[len(p.Scheme)+1:]
+	switch p.Scheme {
+	case "doi":
+		return doiUrl(opaque, u)
+	case "info":
+		if strings.HasPrefix(opaque, "doi/") {
+			return doiUrl(opaque[len("doi/"):], u)
+		}
+	case "arxiv":
+		if opaque != "" && !p.HasAuthority {
+			return "https://arxiv.org/abs/" + opaque
+		}
+	case "isbn":
+		return isbnUrl(opaque, u)
+	case "urn":
+		if strings.HasPrefix(strings.ToLower(opaque), "isbn:") {
+			return isbnUrl(opaque[len("isbn:"):], u)
+		}
+	case "pmid":
+		return pmidUrl(opaque, u)
+	case "http", "https":
+		if !p.HasAuthority || p.HasFragment {
+			return u
+		}
+		path := p.Path
+		if p.HasQuery {
+			path += "?" + p.Query
+		}
+		switch p.Authority {
+		case "doi.org", "dx.doi.org", "www.doi.org":
+			return doiUrl(strings.TrimPrefix(path, "/"), u)
+		case "openlibrary.org", "isbnsearch.org", "www.isbnsearch.org", "www.worldcat.org", "worldcat.org":
+			if strings.HasPrefix(path, "/isbn/") {
+				return isbnUrl(strings.TrimSuffix(path[len("/isbn/"):], "/"), u)
+			}
+		case "pubmed.ncbi.nlm.nih.gov":
+			return pmidUrl(strings.Trim(path, "/"), u)
+		case "www.ncbi.nlm.nih.gov", "ncbi.nlm.nih.gov":
+			if strings.HasPrefix(path, "/pubmed/") {
+				return pmidUrl(strings.Trim(path[len("/pubmed/"):], "/"), u)
+			}
+		}
+	}
+	return u
+}
+
+const doiResolver = "https://doi.org/"
+
+var doiEscaper = strings.NewReplacer("%", "%25", "?", "%3F", "#", "%23", " ", "%20")
+
+func doiUrl(doi, fallback string) string {
+	if unescaped, err := url.PathUnescape(doi); err == nil {
+		doi = unescaped
+	}
+	if !strings.HasPrefix(doi, "10.") || !strings.Contains(doi, "/") {
+		return fallback
+	}
+	return doiResolver + doiEscaper.Replace(doi)
+}
+
+// ISBN-13 without separators or empty string if isbn is invalid.
+func normalizeIsbn(isbn string) string {
+	digits := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(isbn))
+	for i, r := range digits {
+		if r < '0' || r > '9' {
+			if !(r == 'X' && i == 9 && len(digits) == 10) {
+				return ""
+			}
+		}
+	}
+	switch len(digits) {
+	case 13:
+		return digits
+	case 10:
+		digits = "978" + digits[:9]
+		sum := 0
+		for i, r := range digits {
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(r-'0')
+		}
+		return digits + string(rune('0'+(10-sum%10)%10))
+	}
+	return ""
+}
+
+func isbnUrl(isbn, fallback string) string {
+	if normalized := normalizeIsbn(isbn); normalized != "" {
+		return "https://openlibrary.org/isbn/" + normalized
+	}
+	return fallback
+}
+
+func pmidUrl(pmid, fallback string) string {
+	if pmid == "" {
+		return fallback
+	}
+	for _, r := range pmid {
+		if r < '0' || r > '9' {
+			return fallback
+		}
+	}
+	if pmid = strings.TrimLeft(pmid, "0"); pmid == "" {
+		return fallback
+	}
+	return "https://pubmed.ncbi.nlm.nih.gov/" + pmid + "/"
+}
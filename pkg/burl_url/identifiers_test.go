@@ -0,0 +1,51 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import "testing"
+
+func TestIdentifierEquivalence(t *testing.T) {
+	same := [][]string{
+		{"doi:10.1000/ABC-123", "https://doi.org/10.1000/abc-123", "http://dx.doi.org/10.1000%2FABC-123",
+			"info:doi/10.1000/abc-123", "https://www.doi.org/10.1000/Abc-123"},
+		{"doi:10.1002/(SICI)1097-4571", "https://doi.org/10.1002/%28sici%291097-4571"},
+		{"arxiv:2101.00001", "arXiv:2101.00001", "https://arxiv.org/abs/2101.00001",
+			"https://arxiv.org/pdf/2101.00001.pdf"},
+		{"isbn:978-3-16-148410-0", "urn:isbn:3-16-148410-X", "URN:ISBN:9783161484100",
+			"https://isbnsearch.org/isbn/316148410X", "https://openlibrary.org/isbn/9783161484100"},
+		{"pmid:12345678", "https://pubmed.ncbi.nlm.nih.gov/12345678/",
+			"https://www.ncbi.nlm.nih.gov/pubmed/12345678"},
+	}
+	for _, group := range same {
+		expect := CanonicalKey(group[0])
+		for _, url := range group[1:] {
+			if actual := CanonicalKey(url); actual != expect {
+				t.Errorf("%q: %q != %q", url, actual, expect)
+			}
+		}
+	}
+	for url, expect := range map[string]string{
+		"doi:10.1000/ABC":    "https://doi.org/10.1000/ABC",
+		"isbn:0-306-40615-2": "https://openlibrary.org/isbn/9780306406157",
+		"isbn:invalid":       "isbn:invalid",
+		"doi:not-a-doi":      "doi:not-a-doi",
+		"pmid:123":           "https://pubmed.ncbi.nlm.nih.gov/123/",
+	} {
+		if actual := Canonical(url); actual != expect {
+			t.Errorf("%q: %q != %q", url, actual, expect)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// URLs of mail list archives that allow to find a message by its
+// Message-ID. "%s" is replaced by percent-encoded Message-ID without
+// angle brackets, "*" matches any characters except "/". Templates
+// are matched against normalized URLs, see Normalize.
+var MessageArchiveTemplates = []string{
+	"https://list.orgmode.org/%s/",
+	"https://lore.kernel.org/*/%s/",
+	"https://public-inbox.org/*/%s/",
+	"https://inbox.sourceware.org/*/%s/",
+	"https://mid.mail-archive.com/%s",
+	"https://lists.gnu.org/archive/cgi-bin/namazu.cgi?*query=%2Bmsgid%3A%s*",
+}
+
+type messageArchiveMatcher struct {
+	mutex     sync.Mutex
+	templates []string
+	patterns  []*regexp.Regexp
+}
+
+var messageArchives messageArchiveMatcher
+
+func compileMessageArchiveTemplate(template string) *regexp.Regexp {
+	i := strings.Index(template, "%s")
+	if i < 0 {
+		return nil
+	}
+	quote := func(s string) string {
+		return strings.ReplaceAll(regexp.QuoteMeta(s), `\*`, `[^/]*`)
+	}
+	// Archives may have additional pages for a message, e.g. "ID/T/#u".
+	suffix := strings.TrimSuffix(template[i+2:], "/")
+	return regexp.MustCompile("^" + quote(template[:i]) + `([^/?#&]+)` + quote(suffix) + `(?:[/?#].*)?$`)
+}
+
+// Compiled MessageArchiveTemplates, they are updated if the list is changed.
+func (m *messageArchiveMatcher) get() []*regexp.Regexp {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	changed := len(m.templates) != len(MessageArchiveTemplates)
+	for i := 0; !changed && i < len(m.templates); i++ {
+		changed = m.templates[i] != MessageArchiveTemplates[i]
+	}
+	if changed {
+		m.templates = append([]string(nil), MessageArchiveTemplates...)
+		m.patterns = m.patterns[:0]
+		for _, template := range m.templates {
+			if re := compileMessageArchiveTemplate(template); re != nil {
+				m.patterns = append(m.patterns, re)
+			}
+		}
+	}
+	return m.patterns
+}
+
+var midEscaper = strings.NewReplacer(
+	"%", "%25", "/", "%2F", "?", "%3F", "#", "%23", " ", "%20",
+	"<", "%3C", ">", "%3E", "\"", "%22")
+
+// "mid:" URL, RFC 2392, for Message-ID that may be percent-encoded
+// or enclosed into angle brackets. Domain part is case insensitive.
+func midUrl(id, fallback string) string {
+	if unescaped, err := url.PathUnescape(id); err == nil {
+		id = unescaped
+	}
+	id = strings.TrimSpace(id)
+	id = strings.TrimSuffix(strings.TrimPrefix(id, "<"), ">")
+	if id == "" {
+		return fallback
+	}
+	if at := strings.LastIndexByte(id, '@'); at >= 0 {
+		id = id[:at+1] + strings.ToLower(id[at+1:])
+	}
+	return "mid:" + midEscaper.Replace(id)
+}
+
+// References to mail messages are converted to "mid:" URLs:
+// "mid:ID/CID", "news:ID", "notmuch:id:ID", "gnus:GROUP#ID",
+// and archive URLs, see MessageArchiveTemplates.
+func canonicalMessageId(u string) string {
+	p := splitUri(u)
+	if p == nil {
+		return u
+	}
+	opaque := u[len(p.Scheme)+1:]
+	switch p.Scheme {
+	case "mid":
+		// Content-ID of a message part is ignored.
+		if i := strings.IndexByte(opaque, '/'); i >= 0 {
+			opaque = opaque[:i]
+		}
+		return midUrl(opaque, u)
+	case "news":
+		id := opaque
+		if p.HasAuthority {
+			id = strings.TrimPrefix(p.Path, "/")
+		}
+		// Otherwise it is a newsgroup.
+		if strings.Contains(id, "@") || strings.Contains(id, "%40") {
+			return midUrl(id, u)
+		}
+	case "notmuch":
+		if strings.HasPrefix(opaque, "id:") {
+			return midUrl(opaque[len("id:"):], u)
+		}
+	case "gnus":
+		if p.HasFragment {
+			return midUrl(p.Fragment, u)
+		}
+	case "http", "https":
+		for _, re := range messageArchives.get() {
+			if match := re.FindStringSubmatch(u); match != nil {
+				return midUrl(match[1], u)
+			}
+		}
+	}
+	return u
+}
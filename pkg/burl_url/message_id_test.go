@@ -0,0 +1,59 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import "testing"
+
+func TestMessageIdEquivalence(t *testing.T) {
+	same := []string{
+		"mid:87abc.fsf@Example.COM",
+		"mid:%3C87abc.fsf@example.com%3E",
+		"mid:87abc.fsf@example.com/part1@example.com",
+		"news:87abc.fsf@example.com",
+		"news://news.gmane.io/87abc.fsf@example.com",
+		"notmuch:id:87abc.fsf@example.com",
+		"gnus:nntp+news.gmane.io:gmane.emacs.orgmode#<87abc.fsf@EXAMPLE.com>",
+		"https://list.orgmode.org/87abc.fsf@example.com/",
+		"https://list.orgmode.org/87abc.fsf@example.com/T/#u",
+		"https://lore.kernel.org/git/87abc.fsf@example.com/raw",
+		"https://lists.gnu.org/archive/cgi-bin/namazu.cgi?query=%2Bmsgid%3A%3C87abc.fsf%40example.com%3E&submit=Search%21&idxname=emacs-orgmode",
+	}
+	expect := "mid:87abc.fsf@example.com"
+	for _, url := range same {
+		if actual := CanonicalKey(url); actual != expect {
+			t.Errorf("%q: %q != %q", url, actual, expect)
+		}
+	}
+	for _, url := range []string{"news:comp.lang.go", "https://list.orgmode.org/", "notmuch:thread:0001"} {
+		if actual := Canonical(url); actual != Normalize(url) {
+			t.Errorf("%q should not be converted: %q", url, actual)
+		}
+	}
+	if Canonical("mid:Local.Part@example.com") == Canonical("mid:local.part@example.com") {
+		t.Errorf("local part of Message-ID is case sensitive")
+	}
+}
+
+func TestMessageArchiveTemplates(t *testing.T) {
+	defer func(saved []string) { MessageArchiveTemplates = saved }(MessageArchiveTemplates)
+	MessageArchiveTemplates = []string{"https://archive.example.org/message?id=%s"}
+	if actual := Canonical("https://archive.example.org/message?id=abc%40example.org"); actual != "mid:abc@example.org" {
+		t.Errorf("custom template is not applied: %q", actual)
+	}
+	if actual := Canonical("https://list.orgmode.org/abc@example.org/"); actual == "mid:abc@example.org" {
+		t.Errorf("template removed from the list is applied")
+	}
+}
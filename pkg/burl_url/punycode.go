@@ -0,0 +1,183 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Punycode parameters, RFC 3492.
+const (
+	punycodeBase        = 36
+	punycodeTMin        = 1
+	punycodeTMax        = 26
+	punycodeSkew        = 38
+	punycodeDamp        = 700
+	punycodeInitialBias = 72
+	punycodeInitialN    = 128
+)
+
+var errPunycode = errors.New("invalid punycode")
+
+func punycodeAdapt(delta, numPoints int, firstTime bool) int {
+	if firstTime {
+		delta /= punycodeDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / numPoints
+	k := 0
+	for delta > ((punycodeBase-punycodeTMin)*punycodeTMax)/2 {
+		delta /= punycodeBase - punycodeTMin
+		k += punycodeBase
+	}
+	return k + (punycodeBase-punycodeTMin+1)*delta/(delta+punycodeSkew)
+}
+
+func punycodeThreshold(k, bias int) int {
+	switch {
+	case k <= bias:
+		return punycodeTMin
+	case k >= bias+punycodeTMax:
+		return punycodeTMax
+	}
+	return k - bias
+}
+
+func punycodeDigit(d int) byte {
+	if d < 26 {
+		return byte('a' + d)
+	}
+	return byte('0' + d - 26)
+}
+
+func punycodeDigitValue(c byte) (int, bool) {
+	switch {
+	case '0' <= c && c <= '9':
+		return int(c-'0') + 26, true
+	case 'a' <= c && c <= 'z':
+		return int(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int(c - 'A'), true
+	}
+	return 0, false
+}
+
+// Encode a label without the "xn--" prefix.
+func punycodeEncode(label string) (string, error) {
+	if !utf8.ValidString(label) {
+		return "", errPunycode
+	}
+	runes := []rune(label)
+	var b strings.Builder
+	for _, r := range runes {
+		if r < utf8.RuneSelf {
+			b.WriteByte(byte(r))
+		}
+	}
+	basic := b.Len()
+	handled := basic
+	if basic > 0 {
+		b.WriteByte('-')
+	}
+	n, delta, bias := punycodeInitialN, 0, punycodeInitialBias
+	for handled < len(runes) {
+		m := int(utf8.MaxRune) + 1
+		for _, r := range runes {
+			if int(r) >= n && int(r) < m {
+				m = int(r)
+			}
+		}
+		delta += (m - n) * (handled + 1)
+		n = m
+		for _, r := range runes {
+			if int(r) < n {
+				delta++
+			}
+			if int(r) != n {
+				continue
+			}
+			q := delta
+			for k := punycodeBase; ; k += punycodeBase {
+				t := punycodeThreshold(k, bias)
+				if q < t {
+					break
+				}
+				b.WriteByte(punycodeDigit(t + (q-t)%(punycodeBase-t)))
+				q = (q - t) / (punycodeBase - t)
+			}
+			b.WriteByte(punycodeDigit(q))
+			bias = punycodeAdapt(delta, handled+1, handled == basic)
+			delta = 0
+			handled++
+		}
+		delta++
+		n++
+	}
+	return b.String(), nil
+}
+
+// Decode a label without the "xn--" prefix.
+func punycodeDecode(encoded string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndexByte(encoded, '-'); i >= 0 {
+		for j := 0; j < i; j++ {
+			if encoded[j] >= utf8.RuneSelf {
+				return "", errPunycode
+			}
+			output = append(output, rune(encoded[j]))
+		}
+		pos = i + 1
+	}
+	n, i, bias := punycodeInitialN, 0, punycodeInitialBias
+	for pos < len(encoded) {
+		oldI, w := i, 1
+		for k := punycodeBase; ; k += punycodeBase {
+			if pos >= len(encoded) {
+				return "", errPunycode
+			}
+			digit, ok := punycodeDigitValue(encoded[pos])
+			pos++
+			if !ok {
+				return "", errPunycode
+			}
+			i += digit * w
+			t := punycodeThreshold(k, bias)
+			if digit < t {
+				break
+			}
+			w *= punycodeBase - t
+			if i > utf8.MaxRune*len(encoded) || w > utf8.MaxRune*len(encoded) {
+				return "", errPunycode
+			}
+		}
+		count := len(output) + 1
+		bias = punycodeAdapt(i-oldI, count, oldI == 0)
+		n += i / count
+		i %= count
+		if n > utf8.MaxRune || (0xD800 <= n && n <= 0xDFFF) {
+			return "", errPunycode
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
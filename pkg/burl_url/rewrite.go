@@ -0,0 +1,179 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Site-specific rule mapping an alternative form of URL to the preferred one.
+type RewriteRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// Pattern should match whole normalized URL, see Normalize.
+// Replacement may refer to groups as $1 or ${name}.
+func NewRewriteRule(pattern, replacement string) (*RewriteRule, error) {
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return &RewriteRule{re, replacement}, nil
+}
+
+func mustRewriteRule(pattern, replacement string) *RewriteRule {
+	rule, err := NewRewriteRule(pattern, replacement)
+	if err != nil {
+		panic(err)
+	}
+	return rule
+}
+
+// Built-in rules, applied after ones added by user.
+var DefaultRewriteRules = []*RewriteRule{
+	// Archived copies and AMP caches are wrappers around original URLs.
+	mustRewriteRule(`https?://web\.archive\.org/web/[^/]*/(https?:/.*)`, "$1"),
+	mustRewriteRule(`https?://www\.google\.[a-z.]+/amp/s/(.+)`, "https://$1"),
+	mustRewriteRule(`https?://[^/]+\.cdn\.ampproject\.org/[cv]/s/(.+)`, "https://$1"),
+	mustRewriteRule(`https?://youtu\.be/([\w-]+)(?:[/?#].*)?`, "https://www.youtube.com/watch?v=$1"),
+	mustRewriteRule(`https?://(?:www\.|m\.|music\.)?youtube\.com/(?:shorts|embed|live)/([\w-]+)(?:[/?#].*)?`,
+		"https://www.youtube.com/watch?v=$1"),
+	mustRewriteRule(`https?://(?:www\.|m\.|music\.)?youtube\.com/watch\?(?:.*&)?v=([\w-]+)(?:&.*)?(?:#.*)?`,
+		"https://www.youtube.com/watch?v=$1"),
+	// Share identifier, "si" is a meaningful parameter on other sites.
+	mustRewriteRule(`https?://open\.spotify\.com/([^?#]*)\?(.*&)?si=[^&#]*(.*)`,
+		"https://open.spotify.com/$1?$2$3"),
+	mustRewriteRule(`https?://(?:www\.|export\.)?arxiv\.org/(?:abs|pdf)/(.+?)(?:\.pdf)?/?`,
+		"https://arxiv.org/abs/$1"),
+	mustRewriteRule(
+		`https?://([\w-]+)\.m\.(wikipedia|wiktionary|wikibooks|wikiquote|wikisource|wikinews|wikiversity|wikivoyage)\.org/(.*)`,
+		"https://$1.$2.org/$3"),
+	mustRewriteRule(`https?://(?:(?:old|new|np|m|amp)\.)?reddit\.com/(.*)`, "https://www.reddit.com/$1"),
+}
+
+// Rules added by user, they have priority over DefaultRewriteRules.
+// Set it at startup, it is not safe to modify it concurrently.
+var UserRewriteRules []*RewriteRule
+
+// Limit of rules applied to a URL, e.g. for wrappers of rewritten URLs.
+const maxRewriteSteps = 8
+
+func applyRewriteRules(url string) string {
+	for step := 0; step < maxRewriteSteps; step++ {
+		rewritten := url
+		for _, rules := range [][]*RewriteRule{UserRewriteRules, DefaultRewriteRules} {
+			for _, rule := range rules {
+				if rule.Pattern.MatchString(url) {
+					rewritten = Normalize(rule.Pattern.ReplaceAllString(url, rule.Replacement))
+					break
+				}
+			}
+			if rewritten != url {
+				break
+			}
+		}
+		if rewritten == url {
+			break
+		}
+		url = rewritten
+	}
+	return url
+}
+
+// Digest of settings that affect Canonical: rewrite rules, TrackingParams,
+// MessageArchiveTemplates. It allows to detect obsolete stored data.
+func SettingsDigest() string {
+	hash := sha256.New()
+	for _, rules := range [][]*RewriteRule{UserRewriteRules, DefaultRewriteRules} {
+		for _, rule := range rules {
+			fmt.Fprintf(hash, "%s\x00%s\x00", rule.Pattern, rule.Replacement)
+		}
+		hash.Write([]byte{1})
+	}
+	for _, list := range [][]string{TrackingParams, MessageArchiveTemplates} {
+		for _, item := range list {
+			fmt.Fprintf(hash, "%s\x00", item)
+		}
+		hash.Write([]byte{1})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// Split line into pattern and replacement. A tab separates them if present,
+// so both may contain spaces, otherwise the last run of spaces is used,
+// so only pattern may contain spaces.
+func splitRewriteLine(line string) (string, string, bool) {
+	if i := strings.IndexByte(line, '\t'); i >= 0 {
+		pattern := strings.TrimSpace(line[:i])
+		replacement := strings.TrimSpace(line[i+1:])
+		return pattern, replacement, pattern != "" && replacement != ""
+	}
+	i := strings.LastIndexFunc(line, unicode.IsSpace)
+	if i < 0 {
+		return "", "", false
+	}
+	pattern := strings.TrimRightFunc(line[:i], unicode.IsSpace)
+	replacement := line[i+1:]
+	return pattern, replacement, pattern != "" && replacement != ""
+}
+
+// Parse lines with regexp and replacement separated by a tab
+// or by the last run of spaces, empty lines and ones starting
+// with "#" are ignored.
+func ReadRewriteRules(reader io.Reader) ([]*RewriteRule, error) {
+	var rules []*RewriteRule
+	scanner := bufio.NewScanner(reader)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, replacement, ok := splitRewriteLine(line)
+		if !ok {
+			return rules, fmt.Errorf("line %d: pattern and replacement expected", lineNo)
+		}
+		rule, err := NewRewriteRule(pattern, replacement)
+		if err != nil {
+			return rules, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+// Read rules from file. Caller should assign UserRewriteRules once
+// before any URL is processed.
+func LoadRewriteRules(path string) ([]*RewriteRule, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rules, err := ReadRewriteRules(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDefaultRewriteRules(t *testing.T) {
+	same := [][]string{
+		{"https://www.youtube.com/watch?v=dQw4w9WgXcQ", "https://youtu.be/dQw4w9WgXcQ?t=42",
+			"https://m.youtube.com/watch?feature=share&v=dQw4w9WgXcQ", "https://youtube.com/shorts/dQw4w9WgXcQ"},
+		{"https://arxiv.org/abs/2101.00001v2", "http://arxiv.org/pdf/2101.00001v2.pdf"},
+		{"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC",
+			"https://open.spotify.com/track/4uLU6hMCjMI75M1A2tKUQC?si=abc123"},
+		{"https://open.spotify.com/album/1?a=1&b=2#x", "https://open.spotify.com/album/1?si=z&b=2&a=1#x"},
+		{"https://en.wikipedia.org/wiki/URL", "https://en.m.wikipedia.org/wiki/URL"},
+		{"https://www.reddit.com/r/golang/", "https://old.reddit.com/r/golang"},
+		{"https://example.com/article", "https://www.google.com/amp/s/example.com/article",
+			"https://example-com.cdn.ampproject.org/c/s/example.com/article",
+			"https://web.archive.org/web/20200101000000/https://example.com/article"},
+		{"https://en.wikipedia.org/wiki/Go",
+			"http://web.archive.org/web/2021*/https://en.m.wikipedia.org/wiki/Go"},
+	}
+	for _, group := range same {
+		expect := CanonicalKey(group[0])
+		for _, url := range group[1:] {
+			if actual := CanonicalKey(url); actual != expect {
+				t.Errorf("%q: %q != %q", url, actual, expect)
+			}
+		}
+	}
+	if CanonicalKey("https://example.com/page?si=1") == CanonicalKey("https://example.com/page?si=2") {
+		t.Errorf("si parameter should be significant for other sites")
+	}
+}
+
+func TestUserRewriteRules(t *testing.T) {
+	input := `# Mirror of the documentation
+https?://mirror\.example\.org/docs/(.*)  https://docs.example.com/$1
+
+`
+	rules, err := ReadRewriteRules(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(saved []*RewriteRule) { UserRewriteRules = saved }(UserRewriteRules)
+	UserRewriteRules = rules
+	if actual := Canonical("http://mirror.example.org/docs/a?utm_medium=x"); actual != "https://docs.example.com/a" {
+		t.Errorf("user rule is not applied: %q", actual)
+	}
+	if _, err := ReadRewriteRules(strings.NewReader("single-field\n")); err == nil {
+		t.Errorf("error expected for line without replacement")
+	}
+}
+
+func TestSplitRewriteLine(t *testing.T) {
+	cases := []struct {
+		line, pattern, replacement string
+		ok                         bool
+	}{
+		{`a  b`, `a`, `b`, true},
+		{`(?i)x[ ]y (\w+)  https://e.com/$1`, `(?i)x[ ]y (\w+)`, `https://e.com/$1`, true},
+		{"a b\tc d", "a b", "c d", true},
+		{"a\t", "a", "", false},
+		{`single`, "", "", false},
+	}
+	for _, c := range cases {
+		pattern, replacement, ok := splitRewriteLine(c.line)
+		if pattern != c.pattern || replacement != c.replacement || ok != c.ok {
+			t.Errorf("%q: %q %q %v", c.line, pattern, replacement, ok)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+//go:generate python3 gen_unicode_tables.py
+
+type combiningClassRange struct {
+	First, Last rune
+	Class       uint8
+}
+
+var composeMap = map[[2]rune]rune{}
+var decomposeMap = map[rune][2]rune{}
+
+func init() {
+	for _, item := range compositionPairs {
+		composeMap[[2]rune{item[0], item[1]}] = item[2]
+	}
+	for _, item := range canonicalDecompositions {
+		decomposeMap[item[0]] = [2]rune{item[1], item[2]}
+	}
+}
+
+func combiningClass(r rune) uint8 {
+	i := sort.Search(len(combiningClassRanges), func(i int) bool {
+		return combiningClassRanges[i].Last >= r
+	})
+	if i < len(combiningClassRanges) && combiningClassRanges[i].First <= r {
+		return combiningClassRanges[i].Class
+	}
+	return 0
+}
+
+// Hangul syllables, Unicode standard section 3.12.
+const (
+	hangulSBase  = 0xAC00
+	hangulLBase  = 0x1100
+	hangulVBase  = 0x1161
+	hangulTBase  = 0x11A7
+	hangulLCount = 19
+	hangulVCount = 21
+	hangulTCount = 28
+	hangulNCount = hangulVCount * hangulTCount
+	hangulSCount = hangulLCount * hangulNCount
+)
+
+func decomposeRune(dst []rune, r rune) []rune {
+	if s := r - hangulSBase; 0 <= s && s < hangulSCount {
+		dst = append(dst, hangulLBase+s/hangulNCount, hangulVBase+(s%hangulNCount)/hangulTCount)
+		if t := s % hangulTCount; t != 0 {
+			dst = append(dst, hangulTBase+t)
+		}
+		return dst
+	}
+	if pair, ok := decomposeMap[r]; ok {
+		dst = decomposeRune(dst, pair[0])
+		if pair[1] != 0 {
+			dst = decomposeRune(dst, pair[1])
+		}
+		return dst
+	}
+	return append(dst, r)
+}
+
+func composeRunes(first, second rune) (rune, bool) {
+	if l := first - hangulLBase; 0 <= l && l < hangulLCount {
+		if v := second - hangulVBase; 0 <= v && v < hangulVCount {
+			return hangulSBase + (l*hangulVCount+v)*hangulTCount, true
+		}
+	}
+	if s := first - hangulSBase; 0 <= s && s < hangulSCount && s%hangulTCount == 0 {
+		if t := second - hangulTBase; 0 < t && t < hangulTCount {
+			return first + t, true
+		}
+	}
+	composed, ok := composeMap[[2]rune{first, second}]
+	return composed, ok
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// Normalization Form C, UAX #15.
+func composeNFC(s string) string {
+	if isASCII(s) || !utf8.ValidString(s) {
+		return s
+	}
+	runes := make([]rune, 0, len(s))
+	for _, r := range s {
+		runes = decomposeRune(runes, r)
+	}
+	// Canonical ordering of combining marks
+	for i := 1; i < len(runes); i++ {
+		cc := combiningClass(runes[i])
+		if cc == 0 {
+			continue
+		}
+		for j := i; j > 0; j-- {
+			prev := combiningClass(runes[j-1])
+			if prev == 0 || prev <= cc {
+				break
+			}
+			runes[j-1], runes[j] = runes[j], runes[j-1]
+		}
+	}
+	out := runes[:0]
+	starter := -1
+	var lastClass uint8
+	for _, r := range runes {
+		cc := combiningClass(r)
+		adjacent := starter >= 0 && starter == len(out)-1
+		if starter >= 0 && (adjacent || lastClass != 0 && lastClass < cc) {
+			if composed, ok := composeRunes(out[starter], r); ok {
+				out[starter] = composed
+				continue
+			}
+		}
+		if cc == 0 {
+			starter = len(out)
+		}
+		lastClass = cc
+		out = append(out, r)
+	}
+	return string(out)
+}
+
+const hexDigits = "0123456789ABCDEF"
+
+// Characters that are percent-encoded in addition to non-ASCII ones.
+const iriEscaped = " \"<>\\^`{|}"
+
+// Consistent form of path, query, or fragment: percent-encoded
+// UTF-8 sequences are decoded, text is converted to NFC, and non-ASCII
+// characters are percent-encoded again, so IRIs from notes match
+// URLs from the address bar.
+func normalizeIri(s string) string {
+	if isASCII(s) && !strings.Contains(s, "%") {
+		return s
+	}
+	text := composeNFC(unescapeIri(s))
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if c := text[i]; c >= utf8.RuneSelf || c < ' ' || strings.IndexByte(iriEscaped, c) >= 0 {
+			b.WriteByte('%')
+			b.WriteByte(hexDigits[c>>4])
+			b.WriteByte(hexDigits[c&0xF])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Full stops that separate labels of internationalized domain names.
+var idnDotReplacer = strings.NewReplacer("。", ".", "．", ".", "｡", ".")
+
+// Part of UTS #46 mapping that does not need its tables: full-width
+// ASCII forms are replaced, default ignorable characters are removed,
+// and case is folded.
+func idnaMap(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		case r == 0x00AD || r == 0x034F || r == 0x200B || r == 0x2060 || r == 0xFEFF,
+			r >= 0x180B && r <= 0x180D, r >= 0xFE00 && r <= 0xFE0F:
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, label)
+}
+
+// Validity criteria of UTS #46 section 4.1 for a mapped NFC label
+// with an approximation of IDNA 2008 PVALID by general categories:
+// letters, marks, digits, hyphens, and joiners.
+func validIdnLabel(label string) bool {
+	if label == "" || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	if len(label) >= 4 && label[2:4] == "--" {
+		return false
+	}
+	for i, r := range label {
+		mark := unicode.Is(unicode.M, r)
+		if i == 0 && mark {
+			return false
+		}
+		if !mark && r != '-' && r != 0x200C && r != 0x200D && !unicode.IsLetter(r) && !unicode.Is(unicode.Nd, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Host in ASCII form, labels with other characters are mapped
+// to lower case and NFC and encoded by punycode, RFC 5891.
+// Invalid labels are kept as is.
+func normalizeHost(host string) string {
+	if strings.HasPrefix(host, "[") {
+		return strings.ToLower(host)
+	}
+	if strings.Contains(host, "%") {
+		if decoded := unescapeIri(host); utf8.ValidString(decoded) {
+			host = decoded
+		}
+	}
+	labels := strings.Split(idnDotReplacer.Replace(host), ".")
+	for i, label := range labels {
+		if isASCII(label) {
+			label = strings.ToLower(label)
+			labels[i] = label
+			if !strings.HasPrefix(label, "xn--") {
+				continue
+			}
+			decoded, err := punycodeDecode(label[4:])
+			if err != nil || isASCII(decoded) {
+				continue
+			}
+			label = decoded
+		}
+		mapped := composeNFC(idnaMap(label))
+		if isASCII(mapped) {
+			labels[i] = mapped
+		} else if !validIdnLabel(mapped) {
+			continue
+		} else if encoded, err := punycodeEncode(mapped); err == nil {
+			labels[i] = "xn--" + encoded
+		}
+	}
+	return strings.Join(labels, ".")
+}
+
+// Decode percent-encoded non-ASCII bytes, ASCII ones are kept escaped.
+func unescapeIri(s string) string {
+	decoded := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			hi, okHi := unhex(s[i+1])
+			lo, okLo := unhex(s[i+2])
+			if c := hi<<4 | lo; okHi && okLo && c >= utf8.RuneSelf {
+				decoded = append(decoded, c)
+				i += 2
+				continue
+			}
+		}
+		decoded = append(decoded, s[i])
+	}
+	return string(decoded)
+}
@@ -0,0 +1,177 @@
+// Copyright (C) 2022 Max Nikulin
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+
+package burl_url
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestComposeNFC(t *testing.T) {
+	cases := []struct{ text, expect string }{
+		{"\u0438\u0306", "\u0439"},
+		{"A\u030A", "\u00C5"},
+		{"a\u0302\u0323", "\u1EAD"},
+		{"q\u0307\u0323", "q\u0323\u0307"},
+		{"\u1100\u1161\u11A8", "\uAC01"},
+		{"\u00E9", "\u00E9"},
+		{"\u212B", "\u00C5"},
+		{"\u0958", "\u0915\u093C"},
+		{"\u1E9B\u0323", "\u1E9B\u0323"},
+	}
+	for _, c := range cases {
+		if actual := composeNFC(c.text); actual != c.expect {
+			t.Errorf("%+q: %+q != %+q", c.text, actual, c.expect)
+		}
+	}
+}
+
+func parseNormalizationField(field string) (string, error) {
+	var b strings.Builder
+	for _, hex := range strings.Fields(field) {
+		r, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return "", err
+		}
+		b.WriteRune(rune(r))
+	}
+	return b.String(), nil
+}
+
+// Invariants of NFC from NormalizationTest.txt, the file from the Unicode
+// character database may be used instead of generated one.
+func TestNormalizationConformance(t *testing.T) {
+	file, err := os.Open("testdata/NormalizationTest.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	part1 := make(map[rune]bool)
+	part := ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			part = line
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 5 {
+			t.Fatalf("line %d: 5 fields expected", lineNo)
+		}
+		var c [5]string
+		for i := range c {
+			if c[i], err = parseNormalizationField(fields[i]); err != nil {
+				t.Fatalf("line %d: %v", lineNo, err)
+			}
+		}
+		if part == "@Part1" {
+			r, _ := utf8.DecodeRuneInString(c[0])
+			part1[r] = true
+		}
+		for i, expect := range [...]string{c[1], c[1], c[1], c[3], c[3]} {
+			if actual := composeNFC(c[i]); actual != expect {
+				t.Errorf("line %d: NFC(c%d) %+q != %+q", lineNo, i+1, actual, expect)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	for r := rune(0); r <= utf8.MaxRune; r++ {
+		if part1[r] || r >= 0xD800 && r <= 0xDFFF {
+			continue
+		}
+		if s := string(r); composeNFC(s) != s {
+			t.Errorf("%U is changed by NFC", r)
+		}
+	}
+}
+
+func TestPunycode(t *testing.T) {
+	cases := []struct{ label, encoded string }{
+		{"bücher", "bcher-kva"},
+		{"пример", "e1afmkfd"},
+		{"ليهمابتكلموشعربي؟", "egbpdaj6bu4bxfgehfvwxn"},
+	}
+	for _, c := range cases {
+		if actual, err := punycodeEncode(c.label); err != nil || actual != c.encoded {
+			t.Errorf("encode %q: %q != %q, error %v", c.label, actual, c.encoded, err)
+		}
+		if actual, err := punycodeDecode(c.encoded); err != nil || actual != c.label {
+			t.Errorf("decode %q: %q != %q, error %v", c.encoded, actual, c.label, err)
+		}
+	}
+	if _, err := punycodeDecode("a!b"); err == nil {
+		t.Errorf("invalid digit is not reported")
+	}
+}
+
+func TestNormalizeHost(t *testing.T) {
+	cases := []struct{ host, expect string }{
+		{"ＥＸＡＭＰＬＥ。com", "example.com"},
+		{"bü\u00ADcher.de", "xn--bcher-kva.de"},
+		{"XN--BCHER-KVA.de", "xn--bcher-kva.de"},
+		{"xn--bcher-kva.de", "xn--bcher-kva.de"},
+		{"\u0301bücher.de", "\u0301bücher.de"},
+		{"bü--cher.de", "xn--b--cher-n2a.de"},
+		{"ab--ü.de", "ab--ü.de"},
+		{"-bücher.de", "-bücher.de"},
+		{"bü☺cher.de", "bü☺cher.de"},
+		{"xn--zzzzzzzzz.de", "xn--zzzzzzzzz.de"},
+	}
+	for _, c := range cases {
+		if actual := normalizeHost(c.host); actual != c.expect {
+			t.Errorf("%+q: %+q != %+q", c.host, actual, c.expect)
+		}
+	}
+}
+
+func TestNormalizeUnicode(t *testing.T) {
+	expect := "https://xn--e1afmkfd.xn--p1ai/%D0%B9?q=%D0%B9#%D0%B9"
+	for _, url := range []string{
+		expect,
+		"https://пример.рф/й?q=й#й",
+		"https://ПРИМЕР.РФ/\u0438\u0306?q=%D0%B8%CC%86#%d0%b9",
+		"https://%D0%BF%D1%80%D0%B8%D0%BC%D0%B5%D1%80.xn--P1AI/%D0%B9?q=й#й",
+	} {
+		if actual := Normalize(url); actual != expect {
+			t.Errorf("%q: %q != %q", url, actual, expect)
+		}
+	}
+	for _, pair := range [][2]string{
+		{"https://example.com/\u0958", "https://example.com/\u0915\u093C"},
+		{"https://example.com/\u212B", "https://example.com/\u00C5"},
+	} {
+		if CanonicalKey(pair[0]) != CanonicalKey(pair[1]) {
+			t.Errorf("%+q != %+q", pair[0], pair[1])
+		}
+	}
+	if actual := Normalize("https://example.com/%FF%d0"); actual != "https://example.com/%FF%D0" {
+		t.Errorf("invalid UTF-8 should be kept: %q", actual)
+	}
+}